@@ -52,6 +52,8 @@ BEGIN
 	EXECUTE format('DELETE FROM %I.%I WHERE id = $1', _sc_orig, _table)
 	USING _id;
 
+	PERFORM op_trash_log_mark(_sc_trash, _table, ARRAY[_id::text]);
+
 	RETURN 1;
 
 END;
@@ -109,6 +111,8 @@ BEGIN
 	EXECUTE format('DELETE FROM %I.%I WHERE id = $1', _sc_orig, _table)
 	USING _id;
 
+	PERFORM op_trash_log_mark(_sc_trash, _table, ARRAY[_id::text]);
+
 	RETURN 1;
 
 END;
@@ -164,6 +168,8 @@ BEGIN
 
 	-- TODO:
 
+	PERFORM op_trash_log_unmark(_sc_trash, _table, ARRAY[_id::text]);
+
 	RETURN 1;
 
 END;
@@ -0,0 +1,173 @@
+
+
+
+-- 批量移动删除 数字主键，返回每个 id 的结果: 1 成功, -1 记录不存在, -2 表不存在
+CREATE OR REPLACE FUNCTION op_affect_delete_batch(_sc_orig text, _sc_trash text, _table text, _ids bigint[])
+RETURNS TABLE(id bigint, ret int) AS
+$BODY$
+#variable_conflict use_column
+BEGIN
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_orig AND table_name = _table) THEN
+		RETURN QUERY SELECT u.id, -2 FROM unnest(_ids) AS u(id);
+		RETURN;
+	END IF;
+
+	EXECUTE format('CREATE SCHEMA IF NOT EXISTS %I', _sc_trash);
+
+	EXECUTE format('CREATE TABLE IF NOT EXISTS %I.%I (
+		LIKE %I.%I INCLUDING DEFAULTS, PRIMARY KEY (id)
+		)', _sc_trash, _table, _sc_orig, _table);
+
+	PERFORM op_trash_log_ensure(_sc_trash);
+
+	-- 清除 trash 中将被覆盖的旧记录
+	EXECUTE format('DELETE FROM %I.%I t WHERE t.id = ANY($1)
+		AND EXISTS (SELECT 1 FROM %I.%I o WHERE o.id = t.id)', _sc_trash, _table, _sc_orig, _table)
+	USING _ids;
+
+	RETURN QUERY EXECUTE format('WITH moved AS (
+			DELETE FROM %I.%I WHERE id = ANY($1) RETURNING *
+		), ins AS (
+			INSERT INTO %I.%I SELECT * FROM moved RETURNING id
+		), mark AS (
+			INSERT INTO %I.op_trash_log (table_name, id, deleter_id, reason, cascade_key)
+			SELECT $2, ins.id::text, op_trash_deleter(), op_trash_reason(), op_trash_cascade() FROM ins
+			ON CONFLICT (table_name, id) DO UPDATE
+			SET deleted_at = now(), deleter_id = EXCLUDED.deleter_id, reason = EXCLUDED.reason,
+			cascade_key = EXCLUDED.cascade_key
+		)
+		SELECT u.id, CASE WHEN ins.id IS NULL THEN -1 ELSE 1 END
+		FROM unnest($1) AS u(id) LEFT JOIN ins ON ins.id = u.id', _sc_orig, _table, _sc_trash, _table, _sc_trash)
+	USING _ids, _table;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 批量移动删除 字串主键
+CREATE OR REPLACE FUNCTION op_affect_delete_batch(_sc_orig text, _sc_trash text, _table text, _ids text[])
+RETURNS TABLE(id text, ret int) AS
+$BODY$
+#variable_conflict use_column
+BEGIN
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_orig AND table_name = _table) THEN
+		RETURN QUERY SELECT u.id, -2 FROM unnest(_ids) AS u(id);
+		RETURN;
+	END IF;
+
+	EXECUTE format('CREATE SCHEMA IF NOT EXISTS %I', _sc_trash);
+
+	EXECUTE format('CREATE TABLE IF NOT EXISTS %I.%I (
+		LIKE %I.%I INCLUDING DEFAULTS, PRIMARY KEY (id)
+		)', _sc_trash, _table, _sc_orig, _table);
+
+	PERFORM op_trash_log_ensure(_sc_trash);
+
+	-- 清除 trash 中将被覆盖的旧记录
+	EXECUTE format('DELETE FROM %I.%I t WHERE t.id = ANY($1)
+		AND EXISTS (SELECT 1 FROM %I.%I o WHERE o.id = t.id)', _sc_trash, _table, _sc_orig, _table)
+	USING _ids;
+
+	RETURN QUERY EXECUTE format('WITH moved AS (
+			DELETE FROM %I.%I WHERE id = ANY($1) RETURNING *
+		), ins AS (
+			INSERT INTO %I.%I SELECT * FROM moved RETURNING id
+		), mark AS (
+			INSERT INTO %I.op_trash_log (table_name, id, deleter_id, reason, cascade_key)
+			SELECT $2, ins.id::text, op_trash_deleter(), op_trash_reason(), op_trash_cascade() FROM ins
+			ON CONFLICT (table_name, id) DO UPDATE
+			SET deleted_at = now(), deleter_id = EXCLUDED.deleter_id, reason = EXCLUDED.reason,
+			cascade_key = EXCLUDED.cascade_key
+		)
+		SELECT u.id::text, CASE WHEN ins.id IS NULL THEN -1 ELSE 1 END
+		FROM unnest($1) AS u(id) LEFT JOIN ins ON ins.id = u.id', _sc_orig, _table, _sc_trash, _table, _sc_trash)
+	USING _ids, _table;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+
+-- 批量移动删除恢复 数字主键，返回每个 id 的结果: 1 成功, -1 记录不存在或已存在, -2 表不存在, -3 trash 表不存在
+CREATE OR REPLACE FUNCTION op_affect_undelete_batch(_sc_orig text, _sc_trash text, _table text, _ids bigint[])
+RETURNS TABLE(id bigint, ret int) AS
+$BODY$
+#variable_conflict use_column
+BEGIN
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_orig AND table_name = _table) THEN
+		RETURN QUERY SELECT u.id, -2 FROM unnest(_ids) AS u(id);
+		RETURN;
+	END IF;
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_trash AND table_name = _table) THEN
+		RETURN QUERY SELECT u.id, -3 FROM unnest(_ids) AS u(id);
+		RETURN;
+	END IF;
+
+	PERFORM op_trash_log_ensure(_sc_trash);
+
+	RETURN QUERY EXECUTE format('WITH moved AS (
+			DELETE FROM %I.%I t WHERE t.id = ANY($1)
+			AND NOT EXISTS (SELECT 1 FROM %I.%I o WHERE o.id = t.id) RETURNING t.*
+		), ins AS (
+			INSERT INTO %I.%I SELECT * FROM moved RETURNING id
+		), unmark AS (
+			DELETE FROM %I.op_trash_log l USING ins WHERE l.table_name = $2 AND l.id = ins.id::text
+		)
+		SELECT u.id, CASE WHEN ins.id IS NULL THEN -1 ELSE 1 END
+		FROM unnest($1) AS u(id) LEFT JOIN ins ON ins.id = u.id', _sc_trash, _table, _sc_orig, _table, _sc_orig, _table, _sc_trash)
+	USING _ids, _table;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 批量移动删除恢复 字串主键
+CREATE OR REPLACE FUNCTION op_affect_undelete_batch(_sc_orig text, _sc_trash text, _table text, _ids text[])
+RETURNS TABLE(id text, ret int) AS
+$BODY$
+#variable_conflict use_column
+BEGIN
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_orig AND table_name = _table) THEN
+		RETURN QUERY SELECT u.id, -2 FROM unnest(_ids) AS u(id);
+		RETURN;
+	END IF;
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_trash AND table_name = _table) THEN
+		RETURN QUERY SELECT u.id, -3 FROM unnest(_ids) AS u(id);
+		RETURN;
+	END IF;
+
+	PERFORM op_trash_log_ensure(_sc_trash);
+
+	RETURN QUERY EXECUTE format('WITH moved AS (
+			DELETE FROM %I.%I t WHERE t.id = ANY($1)
+			AND NOT EXISTS (SELECT 1 FROM %I.%I o WHERE o.id = t.id) RETURNING t.*
+		), ins AS (
+			INSERT INTO %I.%I SELECT * FROM moved RETURNING id
+		), unmark AS (
+			DELETE FROM %I.op_trash_log l USING ins WHERE l.table_name = $2 AND l.id = ins.id::text
+		)
+		SELECT u.id::text, CASE WHEN ins.id IS NULL THEN -1 ELSE 1 END
+		FROM unnest($1) AS u(id) LEFT JOIN ins ON ins.id = u.id', _sc_trash, _table, _sc_orig, _table, _sc_orig, _table, _sc_trash)
+	USING _ids, _table;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
@@ -0,0 +1,172 @@
+
+
+
+-- 移动删除记录表，保存 trash schema 中每条记录的删除时间、删除者及原因，
+-- 首次创建时为已有的 trash 数据补记当前时间
+CREATE OR REPLACE FUNCTION op_trash_log_ensure(_sc_trash text)
+RETURNS void AS
+$BODY$
+DECLARE
+	_tb text;
+BEGIN
+
+	IF EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_trash AND table_name = 'op_trash_log') THEN
+		-- 升级早于 cascade_key 的记录表
+		IF NOT EXISTS(
+			SELECT column_name FROM information_schema.columns
+			WHERE table_schema = _sc_trash AND table_name = 'op_trash_log' AND column_name = 'cascade_key') THEN
+			EXECUTE format('ALTER TABLE %I.op_trash_log ADD COLUMN IF NOT EXISTS cascade_key text NOT NULL DEFAULT ''''', _sc_trash);
+		END IF;
+		RETURN;
+	END IF;
+
+	EXECUTE format('CREATE SCHEMA IF NOT EXISTS %I', _sc_trash);
+
+	EXECUTE format('CREATE TABLE IF NOT EXISTS %I.op_trash_log (
+		table_name name NOT NULL,
+		id text NOT NULL,
+		deleted_at timestamptz NOT NULL DEFAULT now(),
+		deleter_id text NOT NULL DEFAULT '''',
+		reason text NOT NULL DEFAULT '''',
+		cascade_key text NOT NULL DEFAULT '''',
+		PRIMARY KEY (table_name, id)
+		)', _sc_trash);
+
+	EXECUTE format('CREATE INDEX IF NOT EXISTS op_trash_log_deleted_at_idx ON %I.op_trash_log (table_name, deleted_at)', _sc_trash);
+
+	FOR _tb IN SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_trash AND table_type = 'BASE TABLE' AND table_name <> 'op_trash_log'
+	LOOP
+		PERFORM op_trash_log_backfill(_sc_trash, _tb);
+	END LOOP;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 当前事务中设置的删除者，由 set_config('andvari.deleter_id', ?, true) 设置
+CREATE OR REPLACE FUNCTION op_trash_deleter()
+RETURNS text AS
+$BODY$
+	SELECT coalesce(current_setting('andvari.deleter_id', true), '');
+$BODY$
+LANGUAGE 'sql' STABLE;
+
+-- 当前事务中设置的删除原因
+CREATE OR REPLACE FUNCTION op_trash_reason()
+RETURNS text AS
+$BODY$
+	SELECT coalesce(current_setting('andvari.delete_reason', true), '');
+$BODY$
+LANGUAGE 'sql' STABLE;
+
+-- 当前事务中级联删除的标识，由 set_config('andvari.cascade_key', ?, true) 设置，恢复时按此找到同一次级联的下级记录
+CREATE OR REPLACE FUNCTION op_trash_cascade()
+RETURNS text AS
+$BODY$
+	SELECT coalesce(current_setting('andvari.cascade_key', true), '');
+$BODY$
+LANGUAGE 'sql' STABLE;
+
+-- 记录删除时间、删除者及原因
+CREATE OR REPLACE FUNCTION op_trash_log_mark(_sc_trash text, _table text, _ids text[])
+RETURNS void AS
+$BODY$
+BEGIN
+
+	PERFORM op_trash_log_ensure(_sc_trash);
+
+	EXECUTE format('INSERT INTO %I.op_trash_log (table_name, id, deleter_id, reason, cascade_key)
+		SELECT $1, unnest($2), op_trash_deleter(), op_trash_reason(), op_trash_cascade()
+		ON CONFLICT (table_name, id) DO UPDATE
+		SET deleted_at = now(), deleter_id = EXCLUDED.deleter_id, reason = EXCLUDED.reason,
+		cascade_key = EXCLUDED.cascade_key', _sc_trash)
+	USING _table, _ids;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 恢复后清除删除记录
+CREATE OR REPLACE FUNCTION op_trash_log_unmark(_sc_trash text, _table text, _ids text[])
+RETURNS void AS
+$BODY$
+BEGIN
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_trash AND table_name = 'op_trash_log') THEN
+		RETURN;
+	END IF;
+
+	EXECUTE format('DELETE FROM %I.op_trash_log WHERE table_name = $1 AND id = ANY($2)', _sc_trash)
+	USING _table, _ids;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 为没有删除记录的 trash 数据(早于记录表)补记当前时间，返回补记的数量，记录表须已存在
+CREATE OR REPLACE FUNCTION op_trash_log_backfill(_sc_trash text, _table text)
+RETURNS int AS
+$BODY$
+DECLARE
+	n int;
+BEGIN
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_trash AND table_name = _table) THEN
+		RETURN 0;
+	END IF;
+
+	EXECUTE format('INSERT INTO %I.op_trash_log (table_name, id) SELECT $1, t.id::text FROM %I.%I t
+		ON CONFLICT (table_name, id) DO NOTHING', _sc_trash, _sc_trash, _table)
+	USING _table;
+
+	GET DIAGNOSTICS n = ROW_COUNT;
+	RETURN n;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 清除删除时间早于 _before 的 trash 数据，每次最多 _limit 条，返回清除的数量
+CREATE OR REPLACE FUNCTION op_trash_purge(_sc_trash text, _table text, _before timestamptz, _limit int)
+RETURNS int AS
+$BODY$
+DECLARE
+	n int;
+	_idtype text;
+BEGIN
+
+	IF NOT EXISTS(
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = _sc_trash AND table_name = _table) THEN
+		RETURN 0;
+	END IF;
+
+	PERFORM op_trash_log_ensure(_sc_trash);
+
+	-- 按主键的类型比较，以便使用主键索引
+	SELECT format_type(a.atttypid, a.atttypmod) INTO _idtype FROM pg_attribute a
+	WHERE a.attrelid = format('%I.%I', _sc_trash, _table)::regclass AND a.attname = 'id' AND NOT a.attisdropped;
+
+	EXECUTE format('WITH victims AS (
+			SELECT id FROM %I.op_trash_log WHERE table_name = $1 AND deleted_at < $2
+			ORDER BY deleted_at LIMIT $3
+		), gone AS (
+			DELETE FROM %I.%I t USING victims v WHERE t.id = v.id::%s
+		)
+		DELETE FROM %I.op_trash_log l USING victims v
+		WHERE l.table_name = $1 AND l.id = v.id', _sc_trash, _sc_trash, _table, _idtype, _sc_trash)
+	USING _table, _before, _limit;
+
+	GET DIAGNOSTICS n = ROW_COUNT;
+	RETURN n;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
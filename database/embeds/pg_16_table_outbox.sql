@@ -0,0 +1,19 @@
+
+
+
+-- 事务性发件箱，与模型写入同一事务登记，由 poller 投递到消息服务
+CREATE TABLE IF NOT EXISTS op_outbox (
+	id bigserial NOT NULL,
+	topic text NOT NULL,
+	key text NOT NULL DEFAULT '',
+	payload jsonb NOT NULL DEFAULT '{}',
+	created timestamptz NOT NULL DEFAULT now(),
+	attempts int NOT NULL DEFAULT 0,
+	next_at timestamptz NOT NULL DEFAULT now(),
+	delivered_at timestamptz,
+	last_error text NOT NULL DEFAULT '',
+	PRIMARY KEY (id)
+);
+
+-- 待投递的消息
+CREATE INDEX IF NOT EXISTS op_outbox_pending_idx ON op_outbox (next_at, id) WHERE delivered_at IS NULL;
@@ -0,0 +1,54 @@
+
+
+
+-- 行变更通知，payload 为 {"schema","table","op","id"}，频道为触发器的第一个参数
+CREATE OR REPLACE FUNCTION op_notify_change()
+RETURNS trigger AS
+$BODY$
+DECLARE
+	_id text;
+BEGIN
+
+	IF TG_OP = 'DELETE' THEN
+		_id := OLD.id::text;
+	ELSE
+		_id := NEW.id::text;
+	END IF;
+
+	PERFORM pg_notify(coalesce(TG_ARGV[0], 'andvari_change'), json_build_object(
+		'schema', TG_TABLE_SCHEMA,
+		'table', TG_TABLE_NAME,
+		'op', lower(TG_OP),
+		'id', _id)::text);
+
+	RETURN NULL;
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 为表安装变更通知触发器，已存在时替换
+CREATE OR REPLACE FUNCTION op_notify_install(_schema text, _table text, _channel text)
+RETURNS void AS
+$BODY$
+BEGIN
+
+	EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I.%I', 'op_notify_' || _table, _schema, _table);
+	EXECUTE format('CREATE TRIGGER %I AFTER INSERT OR UPDATE OR DELETE ON %I.%I
+		FOR EACH ROW EXECUTE FUNCTION op_notify_change(%L)', 'op_notify_' || _table, _schema, _table, _channel);
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 移除表的变更通知触发器
+CREATE OR REPLACE FUNCTION op_notify_uninstall(_schema text, _table text)
+RETURNS void AS
+$BODY$
+BEGIN
+
+	EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I.%I', 'op_notify_' || _table, _schema, _table);
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
@@ -0,0 +1,72 @@
+
+
+
+-- 行级安全使用的会话变量，值由事务开始时的 set_config(name, value, true) 设置，未设置时为 NULL
+CREATE OR REPLACE FUNCTION op_rls_id(_name text)
+RETURNS bigint AS
+$BODY$
+	SELECT nullif(current_setting(_name, true), '')::bigint;
+$BODY$
+LANGUAGE 'sql' STABLE;
+
+-- 是否不受行级安全限制(app.bypass = on)
+CREATE OR REPLACE FUNCTION op_rls_bypass()
+RETURNS boolean AS
+$BODY$
+	SELECT coalesce(current_setting('app.bypass', true), '') = 'on';
+$BODY$
+LANGUAGE 'sql' STABLE;
+
+-- 为表启用行级安全并创建策略 op_rls，_columns 中每一列须等于会话变量 app.<列名>，
+-- _nullable 中的列还允许为 0，_force 时对表的所有者同样生效，已存在时替换
+CREATE OR REPLACE FUNCTION op_rls_install(_schema text, _table text, _columns text[], _nullable text[], _force boolean)
+RETURNS void AS
+$BODY$
+DECLARE
+	_col text;
+	_cond text;
+	_expr text := '';
+BEGIN
+
+	IF coalesce(array_length(_columns, 1), 0) = 0 THEN
+		RAISE EXCEPTION 'no columns for rls of %.%', _schema, _table;
+	END IF;
+
+	FOREACH _col IN ARRAY _columns LOOP
+		_cond := format('%I = op_rls_id(%L)', _col, 'app.' || _col);
+		IF _col = ANY(coalesce(_nullable, '{}')) THEN
+			_cond := format('(%I = 0 OR %s)', _col, _cond);
+		END IF;
+		IF length(_expr) > 0 THEN
+			_expr := _expr || ' AND ';
+		END IF;
+		_expr := _expr || _cond;
+	END LOOP;
+	_expr := format('op_rls_bypass() OR (%s)', _expr);
+
+	EXECUTE format('ALTER TABLE %I.%I ENABLE ROW LEVEL SECURITY', _schema, _table);
+	IF _force THEN
+		EXECUTE format('ALTER TABLE %I.%I FORCE ROW LEVEL SECURITY', _schema, _table);
+	ELSE
+		EXECUTE format('ALTER TABLE %I.%I NO FORCE ROW LEVEL SECURITY', _schema, _table);
+	END IF;
+	EXECUTE format('DROP POLICY IF EXISTS op_rls ON %I.%I', _schema, _table);
+	EXECUTE format('CREATE POLICY op_rls ON %I.%I USING (%s) WITH CHECK (%s)', _schema, _table, _expr, _expr);
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
+
+-- 移除表的策略 op_rls 并关闭行级安全
+CREATE OR REPLACE FUNCTION op_rls_uninstall(_schema text, _table text)
+RETURNS void AS
+$BODY$
+BEGIN
+
+	EXECUTE format('DROP POLICY IF EXISTS op_rls ON %I.%I', _schema, _table);
+	EXECUTE format('ALTER TABLE %I.%I NO FORCE ROW LEVEL SECURITY', _schema, _table);
+	EXECUTE format('ALTER TABLE %I.%I DISABLE ROW LEVEL SECURITY', _schema, _table);
+
+END;
+$BODY$
+LANGUAGE 'plpgsql' VOLATILE;
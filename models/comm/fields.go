@@ -252,3 +252,28 @@ func (tsf *TextSearchField) SetTsColumns(cols ...string) {
 func (tsf *TextSearchField) GetTsColumns() []string {
 	return tsf.cols
 }
+
+// VersionField 乐观锁版本号，更新时校验并递增
+type VersionField struct {
+	Version int `bson:"version" json:"version" form:"version" bun:"version,notnull,default:0" pg:"version,notnull,use_zero,default:0" extensions:"x-order=+"` // 版本号
+}
+
+// GetVersion 返回版本号
+func (f *VersionField) GetVersion() int {
+	return f.Version
+}
+
+// SetVersion 设置版本号
+func (f *VersionField) SetVersion(v int) {
+	f.Version = v
+}
+
+// TrashMeta 删除信息，仅在查询删除的记录时读取，不对应表中的列
+type TrashMeta struct {
+	// 删除时间
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bun:"deleted_at,scanonly" extensions:"x-order=~"`
+	// 删除者ID
+	DeleterID string `json:"deleterID,omitempty" bun:"deleter_id,scanonly" extensions:"x-order=~"`
+	// 删除原因
+	DeleteReason string `json:"deleteReason,omitempty" bun:"delete_reason,scanonly" extensions:"x-order=~"`
+}
@@ -76,3 +76,36 @@ func CalculatePageCount(total, limit int) int {
 	}
 	return pageCount
 }
+
+// CursorPager 游标（keyset）分页
+type CursorPager interface {
+	Pager
+	GetCursor() string
+	SetCursors(next, prev string)
+	SkipTotal() bool
+}
+
+// CursorPageSpec 游标分页参数，可与 PageSpec 互换使用
+type CursorPageSpec struct {
+	PageSpec
+
+	// 游标，取自上次结果的 next 或 prev
+	Cursor string `json:"cursor,omitempty" form:"cursor" extensions:"x-order=~"`
+	// 不统计总数
+	NoTotal bool `json:"noTotal,omitempty" form:"noTotal" extensions:"x-order=^"`
+
+	Next string `json:"next,omitempty" swaggerignore:"true"`
+	Prev string `json:"prev,omitempty" swaggerignore:"true"`
+} // @name CursorPageSpec
+
+func (p *CursorPageSpec) GetCursor() string {
+	return p.Cursor
+}
+
+func (p *CursorPageSpec) SetCursors(next, prev string) {
+	p.Next, p.Prev = next, prev
+}
+
+func (p *CursorPageSpec) SkipTotal() bool {
+	return p.NoTotal
+}
@@ -9,6 +9,10 @@ const (
 	Updated = "updated"
 	Meta    = "meta"
 	Src     = "src"
+	Version = "version"
+
+	OwnerID   = "owner_id"
+	CreatorID = "creator_id"
 
 	TsCfg = "ts_cfg"
 	TsVec = "ts_vec"
@@ -8,6 +8,7 @@ import (
 	"io"
 	"log/slog"
 	"reflect"
+	"regexp"
 	"strings"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 
 type alterOption struct {
 	add, drop, change bool      // column
+	index             bool      // index, unique and foreign key
 	output            io.Writer // sql output
 }
 
@@ -37,13 +39,21 @@ func WithAlterDrop() AlterOption {
 	}
 }
 
-// WithAlterChange alter column when column properties changed
+// WithAlterChange alter column when column type, nullability or default changed
 func WithAlterChange() AlterOption {
 	return func(opt *alterOption) {
 		opt.change = true
 	}
 }
 
+// WithAlterIndex diff indexes, unique constraints and foreign keys,
+// creating with WithAlterAdd and dropping with WithAlterDrop
+func WithAlterIndex() AlterOption {
+	return func(opt *alterOption) {
+		opt.index = true
+	}
+}
+
 // WithAlterOutput set sql output file
 func WithAlterOutput(w io.Writer) AlterOption {
 	return func(opt *alterOption) {
@@ -188,9 +198,16 @@ func AlterModel(ctx context.Context, db IDB, schema string, model any, opts ...A
 			return
 		}
 	}
-	// nolint
 	if option.change {
-		// TODO column change
+		cs := diffChangedColumns(fields, cols)
+		if err = changeColumnQuery(ctx, db, schema, tbName, cs, option.output); err != nil {
+			return
+		}
+	}
+	if option.index {
+		if err = syncModelIndexes(ctx, db, schema, tbName, model, option); err != nil {
+			return
+		}
 	}
 
 	return nil
@@ -258,6 +275,9 @@ func formatDataType(dataType, udtName string) string {
 	if dataType == "ARRAY" && strings.HasPrefix(udtName, "_") {
 		return udtName[1:] + "[]"
 	}
+	if dataType == "USER-DEFINED" && len(udtName) > 0 {
+		return udtName
+	}
 	return dataType
 }
 
@@ -351,3 +371,164 @@ func execColumnQuery(ctx context.Context, db IDB, output io.Writer, alter string
 
 	return err
 }
+
+// columnChange 模型字段与表中已有列的差异
+type columnChange struct {
+	field *schema.Field
+	col   tableColumn
+
+	typ  bool // 类型不同
+	null bool // 可空性不同
+	dft  bool // 默认值不同
+}
+
+var typeAliases = map[string]string{
+	"int":         "integer",
+	"int4":        "integer",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"int8":        "bigint",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+	"int2":        "smallint",
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+	"bool":        "boolean",
+	"float8":      "double precision",
+	"float4":      "real",
+	"decimal":     "numeric",
+	"varchar":     "character varying",
+	"char":        "character",
+	"bpchar":      "character",
+	"timestamptz": "timestamp with time zone",
+	"timestamp":   "timestamp without time zone",
+	"timetz":      "time with time zone",
+	"time":        "time without time zone",
+}
+
+// canonicalType 归一化类型名称，忽略长度等修饰
+func canonicalType(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	var suffix string
+	if strings.HasSuffix(s, "[]") {
+		s, suffix = strings.TrimSpace(s[:len(s)-2]), "[]"
+	}
+	if pos := strings.Index(s, "("); pos > 0 {
+		s = strings.TrimSpace(s[:pos])
+	}
+	if v, ok := typeAliases[s]; ok {
+		s = v
+	}
+	return s + suffix
+}
+
+var castRe = regexp.MustCompile(`::[a-z][a-z0-9_ ]*(\[\])?`)
+
+// normalizeDefault 归一化默认值表达式，去掉类型转换
+func normalizeDefault(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "default ")
+	s = castRe.ReplaceAllString(s, "")
+	s = strings.Trim(s, "() ")
+	if s == "current_timestamp" {
+		s = "now"
+	}
+	return s
+}
+
+// implicitDefault 非空列在新增时会补上的默认值
+func implicitDefault(f *schema.Field) string {
+	if !f.NotNull {
+		return ""
+	}
+	def, _ := getColumnDefault(f)
+	return strings.TrimPrefix(def, "DEFAULT ")
+}
+
+func diffChangedColumns(fields []*schema.Field, cols tableColumns) (cs []columnChange) {
+	for _, f := range fields {
+		for _, c := range cols {
+			if c.ColumnName != f.Name {
+				continue
+			}
+			cc := columnChange{field: f, col: c}
+			if len(f.UserSQLType) > 0 {
+				cc.typ = canonicalType(f.UserSQLType) != canonicalType(formatDataType(c.DataType, c.UdtName))
+			}
+			if !f.IsPK {
+				cc.null = f.NotNull == bool(c.IsNullable)
+			}
+			if !strings.HasPrefix(c.ColumnDefault, "nextval(") {
+				if len(f.SQLDefault) > 0 {
+					cc.dft = normalizeDefault(f.SQLDefault) != normalizeDefault(c.ColumnDefault)
+				} else if len(c.ColumnDefault) > 0 {
+					cc.dft = normalizeDefault(implicitDefault(f)) != normalizeDefault(c.ColumnDefault)
+				}
+			}
+			if cc.typ || cc.null || cc.dft {
+				cs = append(cs, cc)
+			}
+			break
+		}
+	}
+	return
+}
+
+func changeColumnQuery(ctx context.Context, db IDB, schema, tbName string, cs []columnChange, output io.Writer) (err error) {
+	const prefix = "ALTER TABLE IF EXISTS %q.%q ALTER COLUMN %q "
+
+	for _, c := range cs {
+		f := c.field
+		if output != nil {
+			comment := fmt.Sprintf(syncTrashColumnSegment, tbName, f.Name, schema)
+			if _, err = output.Write([]byte(comment)); err != nil {
+				return
+			}
+		}
+		if c.typ {
+			sqlType := strings.ToUpper(f.UserSQLType)
+			err = execColumnQuery(ctx, db, output, prefix+"TYPE %s USING %q::%s;",
+				schema, tbName, f.Name, sqlType, f.Name, sqlType)
+			if err != nil {
+				return
+			}
+		}
+		if c.dft {
+			if len(f.SQLDefault) > 0 {
+				err = execColumnQuery(ctx, db, output, prefix+"SET DEFAULT %s;",
+					schema, tbName, f.Name, f.SQLDefault)
+			} else {
+				err = execColumnQuery(ctx, db, output, prefix+"DROP DEFAULT;",
+					schema, tbName, f.Name)
+			}
+			if err != nil {
+				return
+			}
+		}
+		if c.null {
+			if f.NotNull {
+				// fill existing nulls before the constraint
+				fill := f.SQLDefault
+				if len(fill) == 0 {
+					fill = implicitDefault(f)
+				}
+				if len(fill) > 0 {
+					err = execColumnQuery(ctx, db, output, "UPDATE %q.%q SET %q = %s WHERE %q IS NULL;",
+						schema, tbName, f.Name, fill, f.Name)
+					if err != nil {
+						return
+					}
+				}
+				err = execColumnQuery(ctx, db, output, prefix+"SET NOT NULL;",
+					schema, tbName, f.Name)
+			} else {
+				err = execColumnQuery(ctx, db, output, prefix+"DROP NOT NULL;",
+					schema, tbName, f.Name)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
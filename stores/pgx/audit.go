@@ -0,0 +1,88 @@
+package pgx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cupogo/andvari/models/comm"
+)
+
+// AuditLog 模型操作的审计记录
+type AuditLog struct {
+	comm.BaseModel `bun:"table:op_audit_log,alias:oal" json:"-"`
+
+	comm.SerialField
+
+	// 操作时间
+	Created time.Time `bun:"created,notnull,type:timestamptz,default:now()" json:"created"`
+	// 操作类型
+	Operate OperateType `bun:"op,notnull,type:smallint" json:"op"`
+	// 模型名称
+	Model string `bun:"model,notnull,type:name" json:"model"`
+	// 对象主键
+	ObjID string `bun:"obj_id,notnull" json:"objID"`
+	// 操作者
+	ActorID string `bun:"actor_id,notnull" json:"actorID,omitempty"`
+	// 变更的值
+	Changes comm.ChangeValues `bun:"changes,notnull,type:jsonb,nullzero,default:'[]'" json:"changes,omitempty"`
+} // @name AuditLog
+
+type AuditLogs []AuditLog
+
+var auditOnce sync.Once
+
+// RegisterAudit 注册审计表并将 LogAudit 设为 OnOperateModel 的回调，重复调用无效
+func RegisterAudit() {
+	auditOnce.Do(func() {
+		RegisterModel((*AuditLog)(nil))
+		OnOperateModel(LogAudit)
+	})
+}
+
+// LogAudit 保存一条审计记录，操作者取自 ContextWithActor，符合 ModelLogFunc
+func LogAudit(ctx context.Context, db IDB, ot OperateType, obj Model) error {
+	rec := &AuditLog{
+		Created: time.Now(),
+		Operate: ot,
+		Model:   ModelName(obj),
+		ObjID:   auditObjID(obj.GetID()),
+		ActorID: ActorFromContext(ctx),
+	}
+	if cv, ok := obj.(Changeable); ok {
+		rec.Changes = cv.ChangedValues()
+	}
+	if _, err := db.NewInsert().Model(rec).Exec(ctx); err != nil {
+		return fmt.Errorf("create audit log fail: %w", err)
+	}
+	return nil
+}
+
+func auditObjID(id any) string {
+	return fmt.Sprint(id)
+}
+
+// AuditHistory 返回对象的审计记录，最新的在前，limit 为 0 时不限
+func AuditHistory(ctx context.Context, db IDB, obj Model, limit int) (data AuditLogs, err error) {
+	if obj.IsZeroID() {
+		return nil, ErrEmptyPK
+	}
+	q := db.NewSelect().Model(&data).
+		Where("model = ?", ModelName(obj)).
+		Where("obj_id = ?", auditObjID(obj.GetID())).
+		OrderExpr("id DESC")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	err = q.Scan(ctx)
+	return
+}
+
+// AuditHistory 按主键读取模型对象的审计记录
+func (w *DB) AuditHistory(ctx context.Context, obj Model, id any, limit int) (AuditLogs, error) {
+	if !obj.SetID(id) || obj.IsZeroID() {
+		return nil, ErrEmptyPK
+	}
+	return AuditHistory(ctx, w.DB, obj, limit)
+}
@@ -0,0 +1,187 @@
+package pgx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/cupogo/andvari/models/field"
+)
+
+const defaultBatchSize = 500
+
+type batchOption struct {
+	size int
+	args []any
+}
+
+type BatchOption func(opt *batchOption)
+
+// WithBatchSize 每条 INSERT 语句的最大行数，默认 500
+func WithBatchSize(n int) BatchOption {
+	return func(opt *batchOption) {
+		if n > 0 {
+			opt.size = n
+		}
+	}
+}
+
+// WithBatchConflict 冲突时更新，参数同 DoInsert 的 args
+func WithBatchConflict(args ...any) BatchOption {
+	return func(opt *batchOption) {
+		opt.args = args
+	}
+}
+
+// tsRow 插入后需补写的全文检索数据
+type tsRow struct {
+	id  any
+	cfg string
+	txt string
+}
+
+// DoInsertBatch 批量新建同类型的模型，按 batch size 分块执行多行 INSERT
+//
+// 钩子、created 上下文、全文检索及操作日志与 DoInsert 逐行一致。
+// 分块之间不在同一事务内，需要原子性时请在 tx 中调用。
+func DoInsertBatch(ctx context.Context, db IDB, objs []Model, opts ...BatchOption) error {
+	if len(objs) == 0 {
+		return nil
+	}
+	if ok, err := sessionTx(ctx, db, func(ctx context.Context, tx IDB) error {
+		return DoInsertBatch(ctx, tx, objs, opts...)
+	}); ok {
+		return err
+	}
+	opt := &batchOption{size: defaultBatchSize}
+	for _, fn := range opts {
+		fn(opt)
+	}
+
+	typ := reflect.TypeOf(objs[0])
+	isZeroID := true
+	for _, obj := range objs {
+		if obj == nil || reflect.TypeOf(obj) != typ {
+			return ErrInvalidArgs
+		}
+		if !obj.IsZeroID() {
+			isZeroID = false
+		}
+	}
+	for _, obj := range objs {
+		if err := TryToBeforeCreateHooks(ctx, obj); err != nil {
+			return err
+		}
+		applyCreatedFromContext(ctx, obj)
+		applyScopeOnCreate(ctx, obj)
+	}
+
+	name := ModelName(objs[0])
+	argc := len(opt.args)
+	for start := 0; start < len(objs); start += opt.size {
+		end := min(start+opt.size, len(objs))
+		chunk := objs[start:end]
+
+		// bun 需要具体类型的切片
+		rv := reflect.MakeSlice(reflect.SliceOf(typ), len(chunk), len(chunk))
+		for i, obj := range chunk {
+			rv.Index(i).Set(reflect.ValueOf(obj))
+		}
+		slice := rv.Interface()
+
+		q := db.NewInsert().Model(slice)
+		applyInsertConflict(q, isZeroID, opt.args)
+		q.Returning(field.ID)
+		if _, err := q.Exec(ctx); err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "insert batch fail",
+				slog.String("name", name),
+				slog.Int("rows", len(chunk)),
+				slog.Any("err", err),
+			)
+			return fmt.Errorf("create %s batch fail: %w", name, err)
+		}
+
+		if err := updateBatchTextSearch(ctx, db, chunk); err != nil {
+			return fmt.Errorf("create %s batch fail: %w", name, err)
+		}
+
+		logger().LogAttrs(ctx, slog.LevelDebug, "insert batch ok",
+			slog.String("name", name),
+			slog.Int("rows", len(chunk)),
+			slog.Int("argc", argc),
+		)
+	}
+
+	for _, obj := range objs {
+		dbLogModelOp(ctx, db, OperateTypeCreate, obj, argc == 0)
+		if argc > 0 {
+			invalidateCached(ctx, db, obj)
+		}
+		if err := tryAfterHooks(ctx, obj, TryToAfterCreateHooksX); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateBatchTextSearch 按行补写 ts_cfg、ts_vec 及关键词列，多行 INSERT 无法逐行使用表达式
+func updateBatchTextSearch(ctx context.Context, db IDB, objs []Model) error {
+	if _, ok := objs[0].(TextSearchable); !ok {
+		return nil
+	}
+	var kwCol string
+	if vck, ok := objs[0].(IColumnKeyword); ok {
+		kwCol = vck.ColumnKeyword()
+	}
+
+	dftCfg, ftsOk := ftsOf(ctx, db)
+	rows := make([]tsRow, 0, len(objs))
+	for _, obj := range objs {
+		tso := obj.(TextSearchable)
+		cfg := tso.GetTsConfig()
+		if len(cfg) == 0 {
+			cfg = dftCfg
+		}
+		var txt string
+		if ftsOk {
+			if ktg, ok := tso.(KeywordTextGetter); ok {
+				if txt = ktg.GetKeywordText(); len(txt) == 0 {
+					logger().LogAttrs(ctx, slog.LevelInfo, "WARN empty ktg",
+						slog.String("cfg", cfg),
+						slog.String("name", ModelName(obj)),
+					)
+				}
+			}
+		}
+		if len(cfg) > 0 || len(txt) > 0 {
+			rows = append(rows, tsRow{id: obj.GetID(), cfg: cfg, txt: txt})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// 使用位置参数: ?0 表名，?1 关键词列，之后为逐行数据
+	args := []any{Ident(getTableName(db, objs[0])), Ident(kwCol)}
+	sets := []string{
+		"ts_cfg = v.cfg",
+		"ts_vec = CASE WHEN v.txt = '' THEN t.ts_vec ELSE to_tsvector(v.cfg::regconfig, v.txt) END",
+	}
+	if len(kwCol) > 0 {
+		sets = append(sets, "?1 = CASE WHEN v.txt = '' THEN t.?1 ELSE v.txt END")
+	}
+	values := make([]string, len(rows))
+	for i, r := range rows {
+		n := len(args)
+		values[i] = fmt.Sprintf("(?%d, ?%d, ?%d)", n, n+1, n+2)
+		args = append(args, r.id, r.cfg, r.txt)
+	}
+	query := "UPDATE ?0 AS t SET " + strings.Join(sets, ", ") +
+		" FROM (VALUES " + strings.Join(values, ", ") + ") AS v(id, cfg, txt) WHERE t.id = v.id"
+
+	_, err := db.NewRaw(query, args...).Exec(ctx)
+	return err
+}
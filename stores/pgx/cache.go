@@ -0,0 +1,294 @@
+package pgx
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/cupogo/andvari/models/comm"
+)
+
+// ModelCache 模型缓存的后端，键为租户、模型名称及主键，值为模型的深拷贝(见 CacheCloner)
+type ModelCache interface {
+	Get(ctx context.Context, key string) (any, bool)
+	Set(ctx context.Context, key string, val any, ttl time.Duration)
+	Delete(ctx context.Context, keys ...string)
+}
+
+// Cacheable 开启读缓存的模型，GetModel 及 ModelWithPKID(LoadModel) 时读取并从主库填充，
+// 更新、删除、恢复(含批量及级联)及冲突时更新的新建时失效，CacheTTL 不大于 0 时不缓存
+type Cacheable interface {
+	CacheTTL() time.Duration
+}
+
+// CacheCloner 由模型自行深拷贝，存入及读出缓存时使用，
+// 未实现时按导出字段深拷贝(切片、map、指针)，未导出的字段(含未导出的嵌入结构)为浅拷贝
+type CacheCloner interface {
+	CacheClone() Model
+}
+
+// WithModelCache 使用模型缓存，如 NewLRUCache
+func WithModelCache(c ModelCache) OpenOption {
+	return func(opt *openOptions) {
+		opt.cache = c
+	}
+}
+
+type lruEntry struct {
+	key    string
+	val    any
+	expire time.Time
+}
+
+// lruCache 进程内的 LRU 缓存
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewLRUCache 返回进程内的 LRU 缓存，最多保存 size 个对象
+func NewLRUCache(size int) ModelCache {
+	if size <= 0 {
+		size = 1024
+	}
+	return &lruCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) Get(_ context.Context, key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	ent := el.Value.(*lruEntry)
+	if time.Now().After(ent.expire) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return ent.val, true
+}
+
+func (c *lruCache) Set(_ context.Context, key string, val any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expire := time.Now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		ent := el.Value.(*lruEntry)
+		ent.val, ent.expire = val, expire
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, val: val, expire: expire})
+	for c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) Delete(_ context.Context, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.ll.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+// cacheOf 返回所属 DB 的模型缓存
+func cacheOf(ctx context.Context, db IDB) ModelCache {
+	if w := storeOf(ctx, db); w != nil && w.opts != nil {
+		return w.opts.cache
+	}
+	return nil
+}
+
+func cacheKey(ctx context.Context, obj Model) string {
+	return TenantFromContext(ctx) + "/" + ModelName(obj) + "#" + fmt.Sprint(obj.GetID())
+}
+
+// modelCacheFor 返回可用于读取 obj 的缓存、键及有效期，指定了列、在事务中或强制读主库时返回 nil
+func modelCacheFor(ctx context.Context, db IDB, obj Model, columns []string) (ModelCache, string, time.Duration) {
+	co, ok := obj.(Cacheable)
+	if !ok || len(columns) > 0 || InTxQueue(ctx) || PrimaryFromContext(ctx) {
+		return nil, "", 0
+	}
+	if len(ColumnsFromContext(ctx)) > 0 || len(ExcludesFromContext(ctx)) > 0 {
+		return nil, "", 0
+	}
+	ttl := co.CacheTTL()
+	if ttl <= 0 {
+		return nil, "", 0
+	}
+	c := cacheOf(ctx, db)
+	if c == nil {
+		return nil, "", 0
+	}
+	return c, cacheKey(ctx, obj), ttl
+}
+
+// loadCached 从缓存中复制到 obj，不在 ctx 的访问范围内时视为未命中
+func loadCached(ctx context.Context, c ModelCache, key string, obj Model) bool {
+	v, ok := c.Get(ctx, key)
+	if !ok {
+		return false
+	}
+	cached, ok := v.(Model)
+	if !ok {
+		return false
+	}
+	dst, src := reflect.ValueOf(obj), reflect.ValueOf(cached)
+	if dst.Type() != src.Type() || dst.Kind() != reflect.Ptr {
+		return false
+	}
+	if !inScope(ctx, cached) {
+		return false
+	}
+	dst.Elem().Set(reflect.ValueOf(cloneModel(cached)).Elem())
+	return true
+}
+
+// storeCached 保存 obj 的深拷贝
+func storeCached(ctx context.Context, c ModelCache, key string, obj Model, ttl time.Duration) {
+	if reflect.ValueOf(obj).Kind() != reflect.Ptr {
+		return
+	}
+	c.Set(ctx, key, cloneModel(obj), ttl)
+}
+
+var changeModType = reflect.TypeOf(comm.ChangeMod{})
+
+// cloneModel 返回 obj 的深拷贝，变更状态(comm.ChangeMod)不复制
+func cloneModel(obj Model) Model {
+	if cc, ok := obj.(CacheCloner); ok {
+		return cc.CacheClone()
+	}
+	src := reflect.ValueOf(obj)
+	cp := reflect.New(src.Elem().Type())
+	cp.Elem().Set(src.Elem())
+	deepCopy(cp.Elem())
+	return cp.Interface().(Model)
+}
+
+// deepCopy 将 v 中可设置的切片、map、指针及接口替换为新的拷贝
+func deepCopy(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == changeModType {
+			v.SetZero()
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				deepCopy(f)
+			}
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.New(v.Elem().Type())
+		cp.Elem().Set(v.Elem())
+		deepCopy(cp.Elem())
+		v.Set(cp)
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(cp, v)
+		for i := 0; i < cp.Len(); i++ {
+			deepCopy(cp.Index(i))
+		}
+		v.Set(cp)
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			val := reflect.New(it.Value().Type()).Elem()
+			val.Set(it.Value())
+			deepCopy(val)
+			cp.SetMapIndex(it.Key(), val)
+		}
+		v.Set(cp)
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.New(v.Elem().Type()).Elem()
+		cp.Set(v.Elem())
+		deepCopy(cp)
+		v.Set(cp)
+	}
+}
+
+// invalidateCached 使 obj 的缓存失效，在事务中时提交后再失效一次
+func invalidateCached(ctx context.Context, db IDB, obj Model) {
+	if _, ok := obj.(Cacheable); !ok {
+		return
+	}
+	c := cacheOf(ctx, db)
+	if c == nil {
+		return
+	}
+	deleteCached(ctx, c, cacheKey(ctx, obj))
+}
+
+// invalidateCachedAffected 使 table 中 res 成功移动的记录的缓存失效，table 需为已注册的 Cacheable 模型
+func invalidateCachedAffected(ctx context.Context, db IDB, table string, res AffectResults) {
+	c := cacheOf(ctx, db)
+	if c == nil || res.Affected() == 0 {
+		return
+	}
+	keys := make([]string, 0, len(res))
+	for _, r := range res {
+		if !r.OK() {
+			continue
+		}
+		obj := cachedModelOf(db, table)
+		if obj == nil {
+			return
+		}
+		if obj.SetID(r.ID) {
+			keys = append(keys, cacheKey(ctx, obj))
+		}
+	}
+	deleteCached(ctx, c, keys...)
+}
+
+// cachedModelOf 返回表名为 table 且开启缓存的已注册模型的新实例
+func cachedModelOf(db IDB, table string) Model {
+	for _, model := range allmodels {
+		if _, ok := model.(Cacheable); !ok || getTableName(db, model) != table {
+			continue
+		}
+		if obj, ok := newModelOf(model).(Model); ok {
+			return obj
+		}
+	}
+	return nil
+}
+
+func deleteCached(ctx context.Context, c ModelCache, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	c.Delete(ctx, keys...)
+	if InTxQueue(ctx) {
+		AfterCommit(ctx, func(ctx context.Context) {
+			c.Delete(ctx, keys...)
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package pgx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/uptrace/bun/schema"
+)
+
+const maxCascadeDepth = 16
+
+// CascadeItem 级联移动中的一个表及其主键
+type CascadeItem struct {
+	Table string `json:"table"`
+	Depth int    `json:"depth"`
+	IDs   any    `json:"ids"` // []int64 或 []string
+}
+
+// CascadePlan 级联移动的全部表，按发现顺序排列，根模型在前
+type CascadePlan []CascadeItem
+
+// Count 返回涉及的记录总数
+func (p CascadePlan) Count() (n int) {
+	for _, it := range p {
+		n += reflect.ValueOf(it.IDs).Len()
+	}
+	return
+}
+
+// newIDs 按表主键类型返回空的主键切片指针
+func newIDs(tb *schema.Table) (any, error) {
+	if len(tb.PKs) != 1 {
+		return nil, ErrInvalidArgs
+	}
+	switch tb.PKs[0].IndirectType.Kind() {
+	case reflect.String:
+		return &[]string{}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &[]int64{}, nil
+	}
+	return nil, ErrInvalidArgs
+}
+
+// rootIDs 返回只含 id 的主键切片
+func rootIDs(tb *schema.Table, id any) (any, error) {
+	dest, err := newIDs(tb)
+	if err != nil {
+		return nil, err
+	}
+	rs := reflect.ValueOf(dest).Elem()
+	rv := reflect.ValueOf(id)
+	if !rv.IsValid() || !rv.CanConvert(rs.Type().Elem()) {
+		return nil, ErrInvalidArgs
+	}
+	return reflect.Append(rs, rv.Convert(rs.Type().Elem())).Interface(), nil
+}
+
+// cascadeMatch 恢复时用于找出与根记录同一次删除的下级记录
+type cascadeMatch struct {
+	key string    // 级联删除时记录的标识
+	at  time.Time // 早于 cascade_key 的记录按删除时间匹配
+}
+
+// planCascade 在 schema sc 中收集 tb 的 ids 及其声明的下级关系，m 不为空时只收集同一次删除的 trash 记录
+func planCascade(ctx context.Context, db IDB, sc string, tb *schema.Table, ids any, depth int, m *cascadeMatch, plan *CascadePlan) error {
+	*plan = append(*plan, CascadeItem{Table: tb.Name, Depth: depth, IDs: ids})
+
+	cd, ok := reflect.New(tb.Type).Interface().(CascadeDeleter)
+	if !ok {
+		return nil
+	}
+	if depth >= maxCascadeDepth {
+		return fmt.Errorf("cascade too deep at %s: %w", tb.Name, ErrInvalidArgs)
+	}
+	for _, name := range cd.CascadeRelations() {
+		rel, ok := tb.Relations[name]
+		if !ok || (rel.Type != schema.HasOneRelation && rel.Type != schema.HasManyRelation) ||
+			len(rel.BasePKs) != 1 || rel.PolymorphicField != nil {
+			return fmt.Errorf("invalid cascade relation %s.%s: %w", tb.TypeName, name, ErrInvalidArgs)
+		}
+		child := rel.JoinTable
+		dest, err := newIDs(child)
+		if err != nil {
+			return err
+		}
+		pk := Ident(child.PKs[0].Name)
+		q := db.NewSelect().TableExpr("?.? AS c", Ident(sc), Ident(child.Name)).
+			ColumnExpr("c.?", pk).
+			Where("c.? IN (SELECT p.? FROM ?.? AS p WHERE p.? IN (?))",
+				Ident(rel.JoinPKs[0].Name), Ident(rel.BasePKs[0].Name),
+				Ident(sc), Ident(tb.Name), Ident(tb.PKs[0].Name), In(ids))
+		if m != nil {
+			q.Join("JOIN ?.op_trash_log AS l ON l.table_name = ? AND l.id = c.?::text", Ident(sc), child.Name, pk)
+			if len(m.key) > 0 {
+				q.Where("l.cascade_key = ?", m.key)
+			} else {
+				q.Where("l.deleted_at = ?", m.at)
+			}
+		}
+		if err = q.Scan(ctx, dest); err != nil && err != ErrNoRows {
+			return err
+		}
+		if cids := reflect.ValueOf(dest).Elem(); cids.Len() > 0 {
+			if err = planCascade(ctx, db, sc, child, cids.Interface(), depth+1, m, plan); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PlanDeleteCascade 列出删除 obj 时将移动到 trash 的全部记录
+func PlanDeleteCascade(ctx context.Context, db IDB, scDft string, obj Model) (plan CascadePlan, err error) {
+	tb := db.Dialect().Tables().Get(reflect.TypeOf(obj))
+	ids, err := rootIDs(tb, obj.GetID())
+	if err != nil {
+		return
+	}
+	err = planCascade(ctx, db, scDft, tb, ids, 0, nil, &plan)
+	return
+}
+
+// PlanUndeleteCascade 列出恢复 obj 时将从 trash 恢复的全部记录，只包含与 obj 同一次级联删除的下级记录
+func PlanUndeleteCascade(ctx context.Context, db IDB, scCrap string, obj Model) (plan CascadePlan, err error) {
+	tb := db.Dialect().Tables().Get(reflect.TypeOf(obj))
+	ids, err := rootIDs(tb, obj.GetID())
+	if err != nil {
+		return
+	}
+	var m *cascadeMatch
+	var cm cascadeMatch
+	err = db.NewSelect().TableExpr("?.op_trash_log", Ident(scCrap)).Column("cascade_key", "deleted_at").
+		Where("table_name = ?", tb.Name).Where("id = ?::text", obj.GetID()).
+		Scan(ctx, &cm.key, &cm.at)
+	if err == nil {
+		m = &cm
+	} else if err != ErrNoRows {
+		// 记录表不存在时按外键恢复
+		logger().LogAttrs(ctx, slog.LevelInfo, "get trash log fail",
+			slog.String("table", tb.Name),
+			slog.Any("err", err),
+		)
+	}
+	err = planCascade(ctx, db, scCrap, tb, ids, 0, m, &plan)
+	return
+}
+
+// cascadeKey 返回一次级联删除的标识
+func cascadeKey(root CascadeItem) string {
+	return root.Table + ":" + fmt.Sprint(reflect.ValueOf(root.IDs).Index(0).Interface()) +
+		":" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// DoDeleteCascade 按计划从下级到上级移动删除，并为全部记录标记同一个级联标识，根记录不存在时返回 ErrNotFound
+func DoDeleteCascade(ctx context.Context, db IDB, scDft, scCrap string, plan CascadePlan) error {
+	if len(plan) == 0 || reflect.ValueOf(plan[0].IDs).Len() == 0 {
+		return ErrInvalidArgs
+	}
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx Tx) error {
+		key := cascadeKey(plan[0])
+		if _, err := tx.NewRaw("SELECT set_config('andvari.cascade_key', ?, true)", key).Exec(ctx); err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "set cascade key fail",
+				slog.String("key", key),
+				slog.Any("err", err),
+			)
+			return err
+		}
+		for i := len(plan) - 1; i >= 0; i-- {
+			res, err := DoDeleteBatchT(ctx, tx, scDft, scCrap, plan[i].Table, plan[i].IDs)
+			if err != nil {
+				return err
+			}
+			if i == 0 && res.Affected() == 0 {
+				return ErrNotFound
+			}
+		}
+		return nil
+	})
+}
+
+// DoUndeleteCascade 按计划从上级到下级恢复，根记录不在 trash 中时返回 ErrNotFound
+func DoUndeleteCascade(ctx context.Context, db IDB, scDft, scCrap string, plan CascadePlan) error {
+	for i, it := range plan {
+		res, err := DoUndeleteBatchT(ctx, db, scDft, scCrap, it.Table, it.IDs)
+		if err != nil {
+			return err
+		}
+		if i == 0 && res.Affected() == 0 {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// PlanDeleteModel 试运行删除，列出将移动到 trash 的记录，不做修改
+func (w *DB) PlanDeleteModel(ctx context.Context, obj Model, id any) (CascadePlan, error) {
+	if !obj.SetID(id) || obj.IsZeroID() {
+		return nil, ErrEmptyPK
+	}
+	scDft, _ := w.schemas(ctx)
+	return PlanDeleteCascade(ctx, w.DB, scDft, obj)
+}
+
+// PlanUndeleteModel 试运行恢复，列出将从 trash 恢复的记录，不做修改
+func (w *DB) PlanUndeleteModel(ctx context.Context, obj Model, id any) (CascadePlan, error) {
+	if !obj.SetID(id) || obj.IsZeroID() {
+		return nil, ErrEmptyPK
+	}
+	_, scCrap := w.schemas(ctx)
+	return PlanUndeleteCascade(ctx, w.DB, scCrap, obj)
+}
@@ -5,10 +5,16 @@ import "context"
 type contextKey int
 
 const (
-	columnsK  contextKey = iota // 列集
-	relationK                   // 关联
-	createdK                    // 创建时间戳
-	excludesK                   // exclude column 排除掉的列集
+	columnsK      contextKey = iota // 列集
+	relationK                       // 关联
+	createdK                        // 创建时间戳
+	excludesK                       // exclude column 排除掉的列集
+	deleterK                        // 删除者
+	deleteReasonK                   // 删除原因
+	actorK                          // 操作者
+	primaryK                        // 强制读主库
+	dbK                             // 所属的 DB
+	tenantK                         // 租户
 )
 
 func ContextWithColumns(ctx context.Context, columns ...string) context.Context {
@@ -72,3 +78,80 @@ func CreatedFromContext(ctx context.Context) (int64, bool) {
 	}
 	return 0, false
 }
+
+// ContextWithDeleter 将删除者放入 Context，移动删除时记录
+func ContextWithDeleter(ctx context.Context, id string) context.Context {
+	if len(id) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, deleterK, id)
+}
+
+// DeleterFromContext 从 Context 取删除者，未设置时取操作者
+func DeleterFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(deleterK).(string); ok {
+		return v
+	}
+	return ActorFromContext(ctx)
+}
+
+// ContextWithDeleteReason 将删除原因放入 Context，移动删除时记录
+func ContextWithDeleteReason(ctx context.Context, reason string) context.Context {
+	if len(reason) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, deleteReasonK, reason)
+}
+
+// DeleteReasonFromContext 从 Context 取删除原因
+func DeleteReasonFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(deleteReasonK).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// ContextWithActor 将操作者放入 Context，审计及删除记录时使用
+func ContextWithActor(ctx context.Context, id string) context.Context {
+	if len(id) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, actorK, id)
+}
+
+// ActorFromContext 从 Context 取操作者
+func ActorFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(actorK).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// ContextWithPrimary 读取也使用主库，如更新后立即读取
+func ContextWithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryK, true)
+}
+
+func PrimaryFromContext(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryK).(bool)
+	return v
+}
+
+// ContextWithDB 携带 DB 的 schema 及全文检索配置，DB.RunInTx 会自动设置，
+// 直接使用 BeginTx 等得到的 Tx 时需要手动设置
+func ContextWithDB(ctx context.Context, w *DB) context.Context {
+	if w == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, dbK, w)
+}
+
+func DBFromContext(ctx context.Context) *DB {
+	if w, ok := ctx.Value(dbK).(*DB); ok {
+		return w
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package pgx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/cupogo/andvari/models/field"
+)
+
+// pageCursor 游标内容，编码后对调用者不透明
+type pageCursor struct {
+	Keys     []string `json:"k"`           // 排序字段，含 id
+	Values   []any    `json:"v"`           // 边界行的字段值
+	Backward bool     `json:"b,omitempty"` // 向前翻页
+}
+
+func (c *pageCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cursor: %s", ErrInvalidArgs, err)
+	}
+	var c pageCursor
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // keep bigint precision
+	if err = dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%w: cursor: %s", ErrInvalidArgs, err)
+	}
+	if len(c.Keys) == 0 || len(c.Keys) != len(c.Values) {
+		return nil, fmt.Errorf("%w: cursor: mismatched keys", ErrInvalidArgs)
+	}
+	return &c, nil
+}
+
+// cursorSortRules 排序规则加上 id 作为唯一的次序保证
+func cursorSortRules(p Sortable) []sortRule {
+	rules := parseSortRules(p)
+	for _, r := range rules {
+		if sortColumn(r.key) == field.ID {
+			return rules
+		}
+	}
+	var op string
+	if len(rules) > 0 {
+		op = rules[len(rules)-1].op
+	}
+	return append(rules, sortRule{key: field.ID, op: op})
+}
+
+func sortColumn(key string) string {
+	if pos := strings.LastIndex(key, "."); pos >= 0 {
+		return key[pos+1:]
+	}
+	return key
+}
+
+func sortKeys(rules []sortRule) []string {
+	keys := make([]string, len(rules))
+	for i, r := range rules {
+		keys[i] = r.key
+	}
+	return keys
+}
+
+func sortIdent(key string) string {
+	if !strings.Contains(key, ".") {
+		return "?TableAlias.?"
+	}
+	return "?"
+}
+
+// applyKeyset 追加 (a > va) OR (a = va AND b > vb) ... 形式的条件
+func applyKeyset(q *SelectQuery, rules []sortRule, cur *pageCursor) *SelectQuery {
+	return q.WhereGroup(" AND ", func(sq *SelectQuery) *SelectQuery {
+		for i := range rules {
+			sq.WhereGroup(" OR ", func(g *SelectQuery) *SelectQuery {
+				for j := 0; j < i; j++ {
+					g.Where(sortIdent(rules[j].key)+" = ?", Ident(rules[j].key), cur.Values[j])
+				}
+				op := ">"
+				if rules[i].desc() != cur.Backward {
+					op = "<"
+				}
+				return g.Where(sortIdent(rules[i].key)+" "+op+" ?", Ident(rules[i].key), cur.Values[i])
+			})
+		}
+		return sq
+	})
+}
+
+// QueryCursor 根据游标参数进行 keyset 分页查询
+//
+// 排序字段的值取自结果中的边界行，请选择非空列排序，id 会自动作为最后的排序字段。
+func QueryCursor(ctx context.Context, p CursorPager, q *SelectQuery) (count int, err error) {
+	tm, ok := q.GetModel().(bun.TableModel)
+	if !ok {
+		return 0, fmt.Errorf("%w: cursor needs a table model", ErrInvalidArgs)
+	}
+	table := tm.Table()
+	rules := cursorSortRules(p)
+	fields := make([]*schema.Field, len(rules))
+	for i, r := range rules {
+		if fields[i] = table.LookupField(sortColumn(r.key)); fields[i] == nil {
+			return 0, fmt.Errorf("%w: cursor sort key %q", ErrInvalidArgs, r.key)
+		}
+	}
+
+	var cur *pageCursor
+	if s := p.GetCursor(); len(s) > 0 {
+		if cur, err = decodeCursor(s); err != nil {
+			return
+		}
+		if !slices.Equal(cur.Keys, sortKeys(rules)) {
+			return 0, fmt.Errorf("%w: cursor sort changed", ErrInvalidArgs)
+		}
+	}
+
+	if !p.SkipTotal() {
+		if count, err = q.Count(ctx); err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "count failed",
+				slog.Any("pager", p),
+				slog.Any("err", err),
+			)
+			return
+		}
+	}
+
+	backward := cur != nil && cur.Backward
+	if cur != nil {
+		q = applyKeyset(q, rules, cur)
+	}
+	for _, r := range rules {
+		dir := " ASC"
+		if r.desc() != backward {
+			dir = " DESC"
+		}
+		q.OrderExpr(sortIdent(r.key)+dir, Ident(r.key))
+	}
+
+	limit := p.GetLimit()
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if err = q.Limit(limit + 1).Scan(ctx); err != nil && err != sql.ErrNoRows {
+		logger().LogAttrs(ctx, slog.LevelInfo, "select failed",
+			slog.Any("pager", p),
+			slog.Any("err", err),
+		)
+		return
+	}
+	err = nil
+
+	rv := reflect.Indirect(reflect.ValueOf(tm.Value()))
+	if rv.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("%w: cursor needs a slice", ErrInvalidArgs)
+	}
+	hasMore := rv.Len() > limit
+	if hasMore {
+		rv.Set(rv.Slice(0, limit))
+	}
+	if backward {
+		reverseSlice(rv)
+	}
+
+	var next, prev string
+	if n := rv.Len(); n > 0 {
+		if hasMore || backward {
+			next = rowCursor(rules, fields, rv.Index(n-1), false)
+		}
+		if (hasMore && backward) || (cur != nil && !backward) {
+			prev = rowCursor(rules, fields, rv.Index(0), true)
+		}
+	}
+	p.SetCursors(next, prev)
+	if !p.SkipTotal() {
+		p.SetTotal(count)
+	}
+
+	return
+}
+
+func rowCursor(rules []sortRule, fields []*schema.Field, row reflect.Value, backward bool) string {
+	row = reflect.Indirect(row)
+	c := pageCursor{Keys: sortKeys(rules), Values: make([]any, len(fields)), Backward: backward}
+	for i, f := range fields {
+		c.Values[i] = cursorValue(f.Value(row))
+	}
+	return c.Encode()
+}
+
+func cursorValue(v reflect.Value) any {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if vr, ok := v.Interface().(driver.Valuer); ok {
+		if val, err := vr.Value(); err == nil {
+			return val
+		}
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	}
+	return v.Interface()
+}
+
+func reverseSlice(rv reflect.Value) {
+	swap := reflect.Swapper(rv.Interface())
+	for i, j := 0, rv.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
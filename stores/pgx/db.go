@@ -6,21 +6,17 @@ import (
 	"database/sql/driver"
 	"io/fs"
 	"log/slog"
-	"os"
 	"reflect"
-	"runtime"
-	"strconv"
+	"sync"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
-	"github.com/uptrace/bun/extra/bunotel"
 	"github.com/uptrace/bun/migrate"
 	"github.com/uptrace/bun/schema"
 	"github.com/yalue/merged_fs"
 
 	"github.com/cupogo/andvari/models/oid"
-	"github.com/cupogo/andvari/utils"
 )
 
 type IConn = bun.IConn
@@ -32,6 +28,7 @@ type Safe = bun.Safe
 
 type QueryBuilder = bun.QueryBuilder
 type SelectQuery = bun.SelectQuery
+type InsertQuery = bun.InsertQuery
 type QueryAppender = schema.QueryAppender
 type PGError = pgdriver.Error
 
@@ -52,6 +49,7 @@ const (
 	defaultLimit = 20
 )
 
+// 最后一次 Open 的配置，仅作为没有 DB 时的兜底
 var (
 	lastSchema     string
 	lastSchemaCrap string
@@ -59,36 +57,74 @@ var (
 	lastFTSok      bool
 )
 
+// Deprecated: use DB.Schema, 多个 DB 时不可靠
 func LastSchema() string {
 	return lastSchema
 }
 
+// Deprecated: use DB.SchemaCrap, 多个 DB 时不可靠
 func LastSchemaCrap() string {
 	return lastSchemaCrap
 }
 
+// Deprecated: use DB.GetTsCfg, 多个 DB 时不可靠
 func LastFTSConfig() string {
 	return lastFTScfg
 }
 
+// Deprecated: use DB.GetTsCfg, 多个 DB 时不可靠
 func LastFTSEnabled() bool {
 	return lastFTSok
 }
 
+// storeOf 返回操作所属的 DB: db 本身或 ctx 中的 DB(见 ContextWithDB)，都没有时为 nil
+func storeOf(ctx context.Context, db IDB) *DB {
+	if w, ok := db.(*DB); ok {
+		return w
+	}
+	return DBFromContext(ctx)
+}
+
+// schemasOf 返回默认(或 ctx 中租户)及 trash schema，没有所属的 DB 时使用最后一次 Open 的配置
+func schemasOf(ctx context.Context, db IDB) (scDft, scCrap string) {
+	if w := storeOf(ctx, db); w != nil {
+		return w.schemas(ctx)
+	}
+	return lastSchema, lastSchemaCrap
+}
+
+// ftsOf 返回全文检索配置，没有所属的 DB 时使用最后一次 Open 的配置
+func ftsOf(ctx context.Context, db IDB) (cfg string, ok bool) {
+	if w := storeOf(ctx, db); w != nil {
+		return w.ftsCfg, w.ftsOk
+	}
+	return lastFTScfg, lastFTSok
+}
+
 type DB struct {
 	*bun.DB
 
 	scDft, scCrap string // default and trash schemas
 	ftsCfg        string
 	ftsOk         bool
+
+	rs   *replicaSet // 只读副本
+	opts *openOptions
+
+	trashLogs sync.Map // 已确认存在删除记录表的 trash schema
+}
+
+// OpenDB 连接数据库，选项见 OpenOption
+func OpenDB(dsn string, opts ...OpenOption) (db *bun.DB, user string, err error) {
+	return openDB(dsn, newOpenOptions(opts...))
 }
 
-func OpenDB(dsn string) (db *bun.DB, user string, err error) {
-	pgconn := pgdriver.NewConnector(pgdriver.WithDSN(dsn))
+func openDB(dsn string, opt *openOptions) (db *bun.DB, user string, err error) {
+	pgconn := pgdriver.NewConnector(append([]pgdriver.Option{pgdriver.WithDSN(dsn)}, opt.driverOptions()...)...)
 	pgcfg := pgconn.Config()
 
 	sqldb := sql.OpenDB(pgconn)
-	patchPool(sqldb)
+	opt.applyPool(sqldb)
 
 	db = bun.NewDB(sqldb, pgdialect.New(), bun.WithDiscardUnknownColumns())
 
@@ -108,27 +144,35 @@ func OpenDB(dsn string) (db *bun.DB, user string, err error) {
 		slog.String("user", pgcfg.User),
 	)
 
-	patchHookOTEL(db, pgcfg.Database)
-	patchHookDebug(db)
+	opt.applyHooks(db, pgcfg.Database)
 
 	user = pgcfg.User
 
 	return
 }
 
-// Open database with ftscfg
-func Open(dsn string, ftscfg string, _ ...bool) (*DB, error) {
-	db, user, err := OpenDB(dsn)
+// Open database with options
+//
+//	db, err := Open(dsn, WithFTSConfig("zhcfg"), WithMaxOpenConns(16))
+func Open(dsn string, opts ...OpenOption) (*DB, error) {
+	opt := newOpenOptions(opts...)
+	db, user, err := openDB(dsn, opt)
 	if err != nil {
 		return nil, err
 	}
 	ctx := context.Background()
 
+	scDft := user
+	if sc := opt.defaultSchema(); len(sc) > 0 {
+		scDft = sc
+	}
+	ftscfg := opt.ftsCfg
 	w := &DB{DB: db,
-		scDft:  user,
-		scCrap: user + crapSuffix,
+		scDft:  scDft,
+		scCrap: scDft + crapSuffix,
 		ftsCfg: ftscfg,
 		ftsOk:  CheckTsCfg(ctx, db, ftscfg),
+		opts:   opt,
 	}
 
 	lastSchema = w.scDft
@@ -143,6 +187,10 @@ func Open(dsn string, ftscfg string, _ ...bool) (*DB, error) {
 		return nil, err
 	}
 
+	if err := w.AddReplicas(opt.replicas...); err != nil {
+		return nil, err
+	}
+
 	return w, nil
 }
 
@@ -159,33 +207,55 @@ func (w *DB) List(ctx context.Context, spec ListArg, dataptr any) (total int, er
 	return w.ListModel(ctx, spec, dataptr)
 }
 func (w *DB) ListModel(ctx context.Context, spec ListArg, dataptr any) (total int, err error) {
-	q := QueryList(ctx, w, spec, dataptr)
-	if spec.Deleted() {
-		q.ModelTableExpr(w.scCrap + ".?TableName AS ?TableAlias")
-	}
-	if !spec.HasColumn() && !spec.HasExcludeColumn() {
-		q = ApplyQueryContext(ctx, q)
-	}
+	err = w.sessionRead(ctx, func(ctx context.Context, db IDB) (err error) {
+		q := QueryList(ctx, db, spec, dataptr)
+		if !spec.HasColumn() && !spec.HasExcludeColumn() {
+			q = ApplyQueryContext(ctx, q)
+		}
+		if spec.Deleted() {
+			_, scCrap := w.schemas(ctx)
+			if err = w.ensureTrashLog(ctx, scCrap); err != nil {
+				return
+			}
+			q = applyTrashTable(ctx, q, scCrap, spec)
+		}
 
-	return QueryPager(ctx, spec, q)
+		total, err = QueryPager(ctx, spec, q)
+		return
+	})
+	return
 }
 
 func (w *DB) GetModel(ctx context.Context, obj Model, id any, columns ...string) (err error) {
 	if !obj.SetID(id) || obj.IsZeroID() {
 		return ErrEmptyPK
 	}
-	q := w.NewSelect().Model(obj).WherePK()
-
-	if len(columns) > 0 {
-		q.Column(columns...)
-	} else {
-		q = ApplyQueryContext(ctx, q)
+	c, key, ttl := modelCacheFor(ctx, w, obj, columns)
+	if c != nil {
+		if loadCached(ctx, c, key, obj) {
+			return nil
+		}
+		// 副本可能落后于主库，只从主库填充缓存
+		ctx = ContextWithPrimary(ctx)
 	}
+	err = w.sessionRead(ctx, func(ctx context.Context, db IDB) error {
+		q := db.NewSelect().Model(obj).WherePK()
+		applyScope(ctx, obj, q.QueryBuilder())
+
+		if len(columns) > 0 {
+			q.Column(columns...)
+		} else {
+			q = ApplyQueryContext(ctx, q)
+		}
 
-	err = q.Scan(ctx)
+		return q.Scan(ctx)
+	})
 	if err == sql.ErrNoRows {
 		return ErrNotFound
 	}
+	if err == nil && c != nil {
+		storeCached(ctx, c, key, obj, ttl)
+	}
 	return
 }
 
@@ -194,7 +264,26 @@ func (w *DB) DeleteModel(ctx context.Context, obj ModelIdentity, id any) error {
 		return ErrEmptyPK
 	}
 	return w.RunInTx(ctx, nil, func(ctx context.Context, tx Tx) error {
-		return DoDeleteM(ctx, tx, w.scDft, w.scCrap, obj)
+		scDft, scCrap := w.schemas(ctx)
+		if _, ok := obj.(CascadeDeleter); ok {
+			if err := checkScope(ctx, tx, obj); err != nil {
+				return err
+			}
+			if err := TryToBeforeDeleteHooks(ctx, obj); err != nil {
+				return err
+			}
+			plan, err := PlanDeleteCascade(ctx, tx, scDft, obj)
+			if err != nil {
+				return err
+			}
+			if err = DoDeleteCascade(ctx, tx, scDft, scCrap, plan); err != nil {
+				return err
+			}
+			dbLogModelOp(ctx, tx, OperateTypeDelete, obj)
+			invalidateCached(ctx, tx, obj)
+			return tryAfterHooks(ctx, obj, TryToAfterDeleteHooks)
+		}
+		return DoDeleteM(ctx, tx, scDft, scCrap, obj)
 	})
 }
 
@@ -202,8 +291,25 @@ func (w *DB) UndeleteModel(ctx context.Context, obj Model, id any) error {
 	if !obj.SetID(id) || obj.IsZeroID() {
 		return ErrEmptyPK
 	}
-	q := w.NewDelete().Model(obj)
-	return OpUndeletedInTrans(ctx, w.DB, w.Schema(), w.SchemaCrap(), q.GetTableName(), obj.GetID())
+	return w.RunInTx(ctx, nil, func(ctx context.Context, tx Tx) error {
+		scDft, scCrap := w.schemas(ctx)
+		if _, ok := obj.(CascadeDeleter); ok {
+			plan, err := PlanUndeleteCascade(ctx, tx, scCrap, obj)
+			if err != nil {
+				return err
+			}
+			if err = DoUndeleteCascade(ctx, tx, scDft, scCrap, plan); err != nil {
+				return err
+			}
+		} else {
+			q := tx.NewDelete().Model(obj)
+			if err := DoUndeleteT(ctx, tx, scDft, scCrap, q.GetTableName(), obj.GetID()); err != nil {
+				return err
+			}
+		}
+		invalidateCached(ctx, tx, obj)
+		return tryAfterHooks(ctx, obj, TryToAfterUndeleteHooks)
+	})
 }
 
 // OpDeleteOID deprecated by DeleteModel
@@ -212,12 +318,14 @@ func (w *DB) OpDeleteOID(ctx context.Context, table string, id string) error {
 	if err != nil {
 		return err
 	}
-	return OpDeleteInTrans(ctx, w.DB, w.scDft, w.scCrap, table, _id)
+	scDft, scCrap := w.schemas(ctx)
+	return OpDeleteInTrans(ctx, w.DB, scDft, scCrap, table, _id)
 }
 
 // OpDeleteAny deprecated by DeleteModel
 func (w *DB) OpDeleteAny(ctx context.Context, table string, _id any) error {
-	return OpDeleteInTrans(ctx, w.DB, w.scDft, w.scCrap, table, _id)
+	scDft, scCrap := w.schemas(ctx)
+	return OpDeleteInTrans(ctx, w.DB, scDft, scCrap, table, _id)
 }
 
 func (w *DB) OpUndeleteOID(ctx context.Context, table string, id string) error {
@@ -225,7 +333,8 @@ func (w *DB) OpUndeleteOID(ctx context.Context, table string, id string) error {
 	if err != nil {
 		return err
 	}
-	return OpUndeletedInTrans(ctx, w.DB, w.scDft, w.scCrap, table, _id)
+	scDft, scCrap := w.schemas(ctx)
+	return OpUndeletedInTrans(ctx, w.DB, scDft, scCrap, table, _id)
 }
 
 func (w *DB) GetTsCfg() (string, bool) {
@@ -262,15 +371,21 @@ func (w *DB) InitSchemas(ctx context.Context, dropIt bool) error {
 		slog.Int("tables", len(allmodels)),
 		slog.Int("sqls", count),
 	)
+	if err == nil {
+		// 删除记录表，查询删除的记录时关联
+		_ = w.ensureTrashLog(ctx, w.scCrap)
+	}
 	return err
 }
 
 func (w *DB) SyncSchema(ctx context.Context, opts ...AlterOption) error {
-	return syncTrashSchema(ctx, w.DB, w.Schema(), w.SchemaCrap(), opts...)
+	scDft, scCrap := w.schemas(ctx)
+	return syncTrashSchema(ctx, w.DB, scDft, scCrap, opts...)
 }
 
 func (w *DB) AlterModels(ctx context.Context, opts ...AlterOption) error {
-	schemas := []string{w.Schema(), w.SchemaCrap()}
+	scDft, scCrap := w.schemas(ctx)
+	schemas := []string{scDft, scCrap}
 	for i := 0; i < len(allmodels); i++ {
 		for j := 0; j < len(schemas); j++ {
 			if err := AlterModel(ctx, w.DB, schemas[j], allmodels[i], opts...); err != nil {
@@ -278,7 +393,7 @@ func (w *DB) AlterModels(ctx context.Context, opts ...AlterOption) error {
 			}
 		}
 	}
-	return nil
+	return w.ensureTrashLog(ctx, scCrap)
 }
 
 func (w *DB) RunMigrations(ctx context.Context, mfs ...fs.FS) error {
@@ -334,48 +449,6 @@ func ModelName(m any) string {
 	return indirectType(typ).Name()
 }
 
-func patchPool(sqldb *sql.DB) {
-	if s, ok := os.LookupEnv("PGX_MAX_OPEN_X"); ok && len(s) > 0 {
-		if x, err := strconv.Atoi(s); err == nil && x > 0 && x <= 4 {
-			maxOpenConns := x * runtime.GOMAXPROCS(0)
-			sqldb.SetMaxOpenConns(maxOpenConns)
-			sqldb.SetMaxIdleConns(maxOpenConns)
-			logger().LogAttrs(context.Background(), slog.LevelDebug, "set max open = x * maxProcs",
-				slog.Int("x", x),
-			)
-		}
-	}
-}
-
-func patchHookOTEL(db *bun.DB, dbname string) {
-	if s, ok := os.LookupEnv("PGX_BUN_OTEL"); ok && len(s) > 0 {
-		if s == "1" || s == "2" {
-			db.WithQueryHook(bunotel.NewQueryHook(
-				bunotel.WithDBName(dbname),
-				bunotel.WithFormattedQueries(s == "2"),
-				bunotel.WithSpanNameQueryGen(func(qe *bun.QueryEvent) string {
-					if qe.IQuery != nil {
-						if tableName := qe.IQuery.GetTableName(); tableName != "" {
-							return qe.Operation() + " " + utils.CamelCased(tableName, false)
-						}
-					}
-
-					return qe.Operation()
-				}),
-			))
-		}
-	}
-}
-
-func patchHookDebug(db *bun.DB) {
-	if s, ok := os.LookupEnv("PGX_QUERY_DEBUG"); ok && len(s) > 0 {
-		if x, err := strconv.ParseInt(s, 10, 32); err == nil && x > 0 {
-			debugHook := &DebugHook{Verbose: x > 1}
-			db.WithQueryHook(debugHook)
-		}
-	}
-}
-
 func indirectType(t reflect.Type) reflect.Type {
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -0,0 +1,219 @@
+package pgx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// ColumnDrift 列类型差异
+type ColumnDrift struct {
+	Column string `json:"column"`
+	Want   string `json:"want"` // 模型或默认 schema 中的类型
+	Have   string `json:"have"` // 实际的类型
+}
+
+// TableDrift 单个表的结构差异
+type TableDrift struct {
+	Model  string `json:"model,omitempty"`
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+
+	Missing        bool          `json:"missing,omitempty"`        // 表不存在
+	MissingColumns []string      `json:"missingColumns,omitempty"` // 缺少的列
+	ExtraColumns   []string      `json:"extraColumns,omitempty"`   // 多余的列
+	TypeMismatches []ColumnDrift `json:"typeMismatches,omitempty"` // 类型不一致
+}
+
+func (td *TableDrift) Drifted() bool {
+	return td.Missing || len(td.MissingColumns) > 0 || len(td.ExtraColumns) > 0 || len(td.TypeMismatches) > 0
+}
+
+// SchemaReport 注册模型与数据库结构的差异报告
+//
+// 默认 schema 中的表与模型比对，trash schema 中已存在的表与默认 schema 比对，
+// 注册的模型缺少 trash 表时也算作差异。
+type SchemaReport struct {
+	Schema     string       `json:"schema"`
+	SchemaCrap string       `json:"schemaTrash"`
+	Tables     []TableDrift `json:"tables"`
+}
+
+func (r *SchemaReport) HasDrift() bool {
+	return len(r.Tables) > 0
+}
+
+func (r *SchemaReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *SchemaReport) WriteText(w io.Writer) (err error) {
+	p := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format+"\n", args...)
+		}
+	}
+	p("schema: %s, trash: %s", r.Schema, r.SchemaCrap)
+	if !r.HasDrift() {
+		p("no drift")
+		return
+	}
+	for _, td := range r.Tables {
+		name := td.Schema + "." + td.Table
+		if len(td.Model) > 0 {
+			name += " (" + td.Model + ")"
+		}
+		if td.Missing {
+			p("%s: missing table", name)
+			continue
+		}
+		if len(td.MissingColumns) > 0 {
+			p("%s: missing columns: %s", name, strings.Join(td.MissingColumns, ", "))
+		}
+		if len(td.ExtraColumns) > 0 {
+			p("%s: extra columns: %s", name, strings.Join(td.ExtraColumns, ", "))
+		}
+		for _, cd := range td.TypeMismatches {
+			p("%s: column %s want %s, have %s", name, cd.Column, cd.Want, cd.Have)
+		}
+	}
+	return
+}
+
+func getTableColumns(ctx context.Context, db IDB, schema, tbName string) (cols tableColumns, err error) {
+	err = db.NewSelect().Model(&tableColumn{}).
+		Where("table_schema=?", schema).
+		Where("table_name=?", tbName).
+		Scan(ctx, &cols)
+	if err == ErrNoRows {
+		err = nil
+	}
+	return
+}
+
+// diffModelTable 比对模型与默认 schema 中的表
+func diffModelTable(ctx context.Context, db IDB, schema string, model any) (td TableDrift, err error) {
+	tbName := getTableName(db, model)
+	td = TableDrift{Model: ModelName(model), Schema: schema, Table: tbName}
+
+	cols, err := getTableColumns(ctx, db, schema, tbName)
+	if err != nil {
+		return
+	}
+	if len(cols) == 0 {
+		td.Missing = true
+		return
+	}
+
+	fields := db.Dialect().Tables().Get(reflect.TypeOf(model)).Fields
+	as, ds, _ := diffFieldWithColumn(fields, cols)
+	for _, f := range as {
+		td.MissingColumns = append(td.MissingColumns, f.Name)
+	}
+	for _, c := range ds {
+		td.ExtraColumns = append(td.ExtraColumns, c.ColumnName)
+	}
+	for _, c := range diffChangedColumns(fields, cols) {
+		if c.typ {
+			td.TypeMismatches = append(td.TypeMismatches, ColumnDrift{
+				Column: c.field.Name,
+				Want:   c.field.UserSQLType,
+				Have:   formatDataType(c.col.DataType, c.col.UdtName),
+			})
+		}
+	}
+	return
+}
+
+// diffTrashTable 比对 trash 表与默认 schema 中的同名表，required(注册的模型)时缺少 trash 表算作差异
+func diffTrashTable(ctx context.Context, db IDB, defSchema, trashSchema, tbName string, required bool) (td TableDrift, err error) {
+	td = TableDrift{Schema: trashSchema, Table: tbName}
+
+	trashCols, err := getTableColumns(ctx, db, trashSchema, tbName)
+	if err != nil {
+		return
+	}
+	if len(trashCols) == 0 {
+		td.Missing = required
+		return
+	}
+	defCols, err := getTableColumns(ctx, db, defSchema, tbName)
+	if err != nil {
+		return
+	}
+
+	adds, drops := diffTrashColumns(defCols, trashCols)
+	for _, c := range adds {
+		td.MissingColumns = append(td.MissingColumns, c.ColumnName)
+	}
+	for _, c := range drops {
+		td.ExtraColumns = append(td.ExtraColumns, c.ColumnName)
+	}
+	for _, dc := range defCols {
+		for _, tc := range trashCols {
+			if dc.ColumnName != tc.ColumnName {
+				continue
+			}
+			want, have := formatDataType(dc.DataType, dc.UdtName), formatDataType(tc.DataType, tc.UdtName)
+			if canonicalType(want) != canonicalType(have) {
+				td.TypeMismatches = append(td.TypeMismatches, ColumnDrift{Column: dc.ColumnName, Want: want, Have: have})
+			}
+			break
+		}
+	}
+	return
+}
+
+// DiffSchema 报告注册的模型与默认 schema、以及默认 schema 与 trash schema 之间的差异
+func (w *DB) DiffSchema(ctx context.Context) (*SchemaReport, error) {
+	scDft, scCrap := w.schemas(ctx)
+	return DiffSchema(ctx, w.DB, scDft, scCrap)
+}
+
+// DiffSchema 只读地比对 db 中指定的 schema，不做任何初始化，可用于 OpenDB 得到的连接
+func DiffSchema(ctx context.Context, db IDB, scDft, scCrap string) (*SchemaReport, error) {
+	report := &SchemaReport{Schema: scDft, SchemaCrap: scCrap, Tables: []TableDrift{}}
+
+	seen := make(map[string]string, len(allmodels))
+	for _, model := range allmodels {
+		td, err := diffModelTable(ctx, db, scDft, model)
+		if err != nil {
+			return nil, err
+		}
+		if !td.Missing {
+			seen[td.Table] = td.Model
+		}
+		if td.Drifted() {
+			report.Tables = append(report.Tables, td)
+		}
+	}
+
+	// all base tables in default schema, registered or not
+	var defTables []table
+	err := db.NewSelect().Model(&defTables).
+		Where("table_schema=?", scDft).
+		Where("table_type=?", TableTypeBase).
+		Order("table_name").
+		Scan(ctx)
+	if err != nil && err != ErrNoRows {
+		return nil, err
+	}
+	for _, dt := range defTables {
+		model, registered := seen[dt.TableName]
+		td, err := diffTrashTable(ctx, db, scDft, scCrap, dt.TableName, registered)
+		if err != nil {
+			return nil, err
+		}
+		if td.Drifted() {
+			td.Model = model
+			report.Tables = append(report.Tables, td)
+		}
+	}
+
+	return report, nil
+}
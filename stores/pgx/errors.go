@@ -11,6 +11,7 @@ var (
 	ErrEmptyKey  = errors.New("empty key")
 	ErrDuplicate = errors.New("duplicate")
 	ErrInternal  = errors.New("internal error")
+	ErrConflict  = errors.New("version conflict")
 
 	ErrInvalidArgs = errors.New("invalid args")
 	ErrInvalidID   = errors.New("invalid id")
@@ -3,7 +3,10 @@ package pgx
 import (
 	"context"
 	"log/slog"
+	"slices"
+	"sync"
 
+	"github.com/cupogo/andvari/models/comm"
 	"github.com/cupogo/andvari/models/field"
 )
 
@@ -15,14 +18,146 @@ const (
 	OperateTypeDelete = field.OperateTypeDelete
 )
 
+// ModelEvent 模型操作事件
+type ModelEvent struct {
+	Operate OperateType
+	Model   string // 模型名称，同 ModelName
+	Obj     Model
+
+	Columns []string          // 变更的列
+	Changes comm.ChangeValues // 变更的值
+}
+
+// LogDisabled 模型是否关闭了操作日志
+func (ev ModelEvent) LogDisabled() bool {
+	if cv, ok := ev.Obj.(Changeable); ok {
+		return cv.DisableLog()
+	}
+	return false
+}
+
+type EventHandler func(ctx context.Context, db IDB, ev ModelEvent) error
+
+type ModelLogFunc func(ctx context.Context, db IDB, ot OperateType, obj Model) error
+
+type subscriber struct {
+	id          int
+	fn          EventHandler
+	ops         OperateType
+	models      []string
+	afterCommit bool
+}
+
+func (s *subscriber) match(ev ModelEvent) bool {
+	if s.ops != 0 && s.ops&ev.Operate == 0 {
+		return false
+	}
+	return len(s.models) == 0 || slices.Contains(s.models, ev.Model)
+}
+
+type SubscribeOption func(s *subscriber)
+
+// WithOperate 只接收指定的操作类型
+func WithOperate(ots ...OperateType) SubscribeOption {
+	return func(s *subscriber) {
+		for _, ot := range ots {
+			s.ops |= ot
+		}
+	}
+}
+
+// WithModels 只接收指定名称(ModelName)的模型
+func WithModels(names ...string) SubscribeOption {
+	return func(s *subscriber) {
+		s.models = append(s.models, names...)
+	}
+}
+
+// WithAfterCommit 在 DB.RunInTx 提交后才接收，回滚时丢弃，不在事务中时立即接收
+func WithAfterCommit() SubscribeOption {
+	return func(s *subscriber) {
+		s.afterCommit = true
+	}
+}
+
 var (
-	operateModelLogFn ModelLogFunc
+	eventMu     sync.RWMutex
+	eventSubs   []*subscriber
+	eventLastID int
+
+	onOperateMu    sync.Mutex
+	onOperateUnsub func() // OnOperateModel 当前的订阅
 )
 
-type ModelLogFunc func(ctx context.Context, db IDB, ot OperateType, obj Model) error
+// Subscribe 订阅模型操作事件，可多次订阅，返回取消订阅的函数
+func Subscribe(fn EventHandler, opts ...SubscribeOption) (unsubscribe func()) {
+	s := &subscriber{fn: fn}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	eventMu.Lock()
+	eventLastID++
+	s.id = eventLastID
+	eventSubs = append(eventSubs, s)
+	eventMu.Unlock()
+
+	return func() {
+		eventMu.Lock()
+		defer eventMu.Unlock()
+		eventSubs = slices.DeleteFunc(eventSubs, func(e *subscriber) bool { return e.id == s.id })
+	}
+}
 
+// OnOperateModel 设置可变更模型操作的回调，替换之前设置的回调，nil 时清除，DisableLog 的模型不通知。
+// 需要多个订阅者时使用 Subscribe
 func OnOperateModel(fn ModelLogFunc) {
-	operateModelLogFn = fn
+	onOperateMu.Lock()
+	defer onOperateMu.Unlock()
+	if onOperateUnsub != nil {
+		onOperateUnsub()
+		onOperateUnsub = nil
+	}
+	if fn == nil {
+		return
+	}
+	onOperateUnsub = Subscribe(func(ctx context.Context, db IDB, ev ModelEvent) error {
+		if _, ok := ev.Obj.(Changeable); !ok || ev.LogDisabled() {
+			return nil
+		}
+		return fn(ctx, db, ev.Operate, ev.Obj)
+	})
+}
+
+// PublishModelEvent 向订阅者发布事件
+func PublishModelEvent(ctx context.Context, db IDB, ev ModelEvent) {
+	eventMu.RLock()
+	subs := slices.Clone(eventSubs)
+	eventMu.RUnlock()
+
+	for _, s := range subs {
+		if !s.match(ev) {
+			continue
+		}
+		if s.afterCommit {
+			txQueueFromContext(ctx).push(ctx, db, func(ctx context.Context, db IDB) {
+				callEventHandler(ctx, db, s, ev)
+			})
+		} else {
+			callEventHandler(ctx, db, s, ev)
+		}
+	}
+}
+
+func callEventHandler(ctx context.Context, db IDB, s *subscriber, ev ModelEvent) {
+	if err := s.fn(ctx, db, ev); err != nil {
+		logger().LogAttrs(ctx, slog.LevelInfo, "call event handler fail",
+			slog.String("name", ev.Model),
+			slog.Any("ot", ev.Operate),
+			slog.Int("sub", s.id),
+			slog.Any("err", err),
+		)
+	}
 }
 
 func dbLogModelOp(ctx context.Context, db IDB, ot OperateType, obj Model, conds ...bool) {
@@ -31,14 +166,10 @@ func dbLogModelOp(ctx context.Context, db IDB, ot OperateType, obj Model, conds
 			return
 		}
 	}
-	if ov, ok := obj.(Changeable); ok && !ov.DisableLog() && operateModelLogFn != nil {
-		err := operateModelLogFn(ctx, db, ot, obj)
-		if err != nil {
-			logger().LogAttrs(ctx, slog.LevelInfo, "call operateModelLogFn fail",
-			slog.String("name", ModelName(obj)),
-			slog.Any("ot", ot),
-			slog.Any("err", err),
-		)
-		}
+	ev := ModelEvent{Operate: ot, Model: ModelName(obj), Obj: obj}
+	if cv, ok := obj.(Changeable); ok {
+		ev.Columns = cv.GetChanges()
+		ev.Changes = cv.ChangedValues()
 	}
+	PublishModelEvent(ctx, db, ev)
 }
@@ -1,6 +1,9 @@
 package pgx
 
-import "context"
+import (
+	"context"
+	"log/slog"
+)
 
 // CreatingHook call before saving new model into database
 type CreatingHook interface {
@@ -48,6 +51,58 @@ type SavingHookX interface {
 	SavingX(context.Context) error
 }
 
+// CreatedHookX is called after a model has been created
+type CreatedHookX interface {
+	CreatedX(context.Context) error
+}
+
+// UpdatedHookX is called after a model has been updated
+type UpdatedHookX interface {
+	UpdatedX(context.Context) error
+}
+
+// SavedHookX is called after a model (new or existing) has been saved
+type SavedHookX interface {
+	SavedX(context.Context) error
+}
+
+// DeletingHook call before moving model into trash, return error to cancel it.
+// The model may have only ID when deleting by id.
+type DeletingHook interface {
+	Deleting() error
+}
+
+// DeletingHookX is called before a model is moved into trash, return error to cancel it
+type DeletingHookX interface {
+	DeletingX(context.Context) error
+}
+
+// DeletedHook call after model moved into trash
+type DeletedHook interface {
+	Deleted() error
+}
+
+// DeletedHookX is called after a model is moved into trash
+type DeletedHookX interface {
+	DeletedX(context.Context) error
+}
+
+// UndeletedHook call after model restored from trash
+type UndeletedHook interface {
+	Undeleted() error
+}
+
+// UndeletedHookX is called after a model is restored from trash
+type UndeletedHookX interface {
+	UndeletedX(context.Context) error
+}
+
+// AfterCommitHooker 返回 true 时，在 DB.RunInTx 中 Created、Updated、Saved、Deleted、Undeleted
+// 钩子延迟到事务提交后执行，回滚时丢弃，延迟执行的钩子错误只记录日志
+type AfterCommitHooker interface {
+	HooksAfterCommit() bool
+}
+
 func TryToBeforeCreateHooks(ctx context.Context, model any) error {
 	if hook, ok := model.(CreatingHookX); ok {
 		if err := hook.CreatingX(ctx); err != nil {
@@ -96,30 +151,52 @@ func TryToBeforeUpdateHooks(ctx context.Context, model any) error {
 	return nil
 }
 
+// Deprecated: use TryToAfterCreateHooksX
 func TryToAfterCreateHooks(model any) error {
-	if hook, ok := model.(CreatedHook); ok {
-		if err := hook.Created(); err != nil {
+	return TryToAfterCreateHooksX(context.Background(), model)
+}
+
+// TryToAfterCreateHooksX 同 TryToAfterCreateHooks，并传递 ctx 给 CreatedHookX 及 SavedHookX
+func TryToAfterCreateHooksX(ctx context.Context, model any) error {
+	if hook, ok := model.(CreatedHookX); ok {
+		if err := hook.CreatedX(ctx); err != nil {
 			return err
 		}
-	}
-
-	if hook, ok := model.(SavedHook); ok {
-		if err := hook.Saved(); err != nil {
+	} else if hook, ok := model.(CreatedHook); ok {
+		if err := hook.Created(); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return tryToSavedHooks(ctx, model)
 }
 
+// Deprecated: use TryToAfterUpdateHooksX
 func TryToAfterUpdateHooks(model any) error {
-	if hook, ok := model.(UpdatedHook); ok {
+	return TryToAfterUpdateHooksX(context.Background(), model)
+}
+
+// TryToAfterUpdateHooksX 同 TryToAfterUpdateHooks，并传递 ctx 给 UpdatedHookX 及 SavedHookX
+func TryToAfterUpdateHooksX(ctx context.Context, model any) error {
+	if hook, ok := model.(UpdatedHookX); ok {
+		if err := hook.UpdatedX(ctx); err != nil {
+			return err
+		}
+	} else if hook, ok := model.(UpdatedHook); ok {
 		if err := hook.Updated(); err != nil {
 			return err
 		}
 	}
 
-	if hook, ok := model.(SavedHook); ok {
+	return tryToSavedHooks(ctx, model)
+}
+
+func tryToSavedHooks(ctx context.Context, model any) error {
+	if hook, ok := model.(SavedHookX); ok {
+		if err := hook.SavedX(ctx); err != nil {
+			return err
+		}
+	} else if hook, ok := model.(SavedHook); ok {
 		if err := hook.Saved(); err != nil {
 			return err
 		}
@@ -127,3 +204,52 @@ func TryToAfterUpdateHooks(model any) error {
 
 	return nil
 }
+
+// TryToBeforeDeleteHooks 返回错误时取消删除
+func TryToBeforeDeleteHooks(ctx context.Context, model any) error {
+	if hook, ok := model.(DeletingHookX); ok {
+		return hook.DeletingX(ctx)
+	} else if hook, ok := model.(DeletingHook); ok {
+		return hook.Deleting()
+	}
+
+	return nil
+}
+
+func TryToAfterDeleteHooks(ctx context.Context, model any) error {
+	if hook, ok := model.(DeletedHookX); ok {
+		return hook.DeletedX(ctx)
+	} else if hook, ok := model.(DeletedHook); ok {
+		return hook.Deleted()
+	}
+
+	return nil
+}
+
+func TryToAfterUndeleteHooks(ctx context.Context, model any) error {
+	if hook, ok := model.(UndeletedHookX); ok {
+		return hook.UndeletedX(ctx)
+	} else if hook, ok := model.(UndeletedHook); ok {
+		return hook.Undeleted()
+	}
+
+	return nil
+}
+
+// tryAfterHooks 执行 after 钩子，模型需要时登记到事务提交后
+func tryAfterHooks(ctx context.Context, model any, fn func(ctx context.Context, model any) error) error {
+	if ach, ok := model.(AfterCommitHooker); ok && ach.HooksAfterCommit() {
+		queued := txQueueFromContext(ctx).add(func(ctx context.Context, _ IDB) {
+			if err := fn(ctx, model); err != nil {
+				logger().LogAttrs(ctx, slog.LevelInfo, "after commit hook fail",
+					slog.String("name", ModelName(model)),
+					slog.Any("err", err),
+				)
+			}
+		})
+		if queued {
+			return nil
+		}
+	}
+	return fn(ctx, model)
+}
@@ -0,0 +1,422 @@
+package pgx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	syncIndexSegment = "\n-- \n" +
+		"-- Name: %s; Type: %s; Schema: %s \n" +
+		"-- \n"
+)
+
+// IndexDef 模型声明的索引，按名称与库中的索引比对
+type IndexDef struct {
+	Name    string   // 索引名，为空时生成 <table>_<columns>_idx
+	Columns []string // 列名或表达式
+	Unique  bool
+	Using   string // btree, gin, gist ...，默认 btree
+	Where   string // 部分索引条件
+}
+
+// IndexName 返回索引名
+func (d IndexDef) IndexName(tbName string) string {
+	if len(d.Name) > 0 {
+		return d.Name
+	}
+	return constraintName(tbName, d.Columns, "idx")
+}
+
+// concurrently 事务中不能 CREATE/DROP INDEX CONCURRENTLY
+func concurrently(db IDB) bool {
+	switch db.(type) {
+	case Tx, *Tx:
+		return false
+	}
+	return true
+}
+
+func (d IndexDef) createSQL(schema, tbName string, concurrent bool) string {
+	var b strings.Builder
+	b.WriteString("CREATE ")
+	if d.Unique {
+		b.WriteString("UNIQUE ")
+	}
+	using := d.Using
+	if len(using) == 0 {
+		using = "btree"
+	}
+	b.WriteString("INDEX ")
+	if concurrent {
+		b.WriteString("CONCURRENTLY ")
+	}
+	fmt.Fprintf(&b, "IF NOT EXISTS %q ON %q.%q USING %s (%s)",
+		d.IndexName(tbName), schema, tbName, using, strings.Join(d.Columns, ", "))
+	if len(d.Where) > 0 {
+		b.WriteString(" WHERE ")
+		b.WriteString(d.Where)
+	}
+	b.WriteByte(';')
+	return b.String()
+}
+
+// tableIndex index in db
+type tableIndex struct {
+	schema.BaseModel `bun:"table:pg_indexes"` // nolint
+
+	IndexName string `bun:"indexname"`
+	IndexDef  string `bun:"indexdef"`
+}
+
+type tableIndexes []tableIndex
+
+func (z tableIndexes) Has(name string) bool {
+	for _, ti := range z {
+		if ti.IndexName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tableConstraint unique or foreign key constraint in db
+type tableConstraint struct {
+	Name       string   `bun:"conname"`
+	Type       string   `bun:"contype"` // u: unique, f: foreign key
+	Columns    []string `bun:"columns,array"`
+	RefTable   string   `bun:"ref_table"`
+	RefColumns []string `bun:"ref_columns,array"`
+	OnRule     string   `bun:"-"` // ON UPDATE / ON DELETE of model
+}
+
+func (c tableConstraint) same(o tableConstraint) bool {
+	return c.Type == o.Type && slices.Equal(c.Columns, o.Columns) &&
+		c.RefTable == o.RefTable && slices.Equal(c.RefColumns, o.RefColumns)
+}
+
+type tableConstraints []tableConstraint
+
+func (z tableConstraints) Find(c tableConstraint) bool {
+	for _, o := range z {
+		if o.same(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z tableConstraints) HasName(name string) bool {
+	for _, o := range z {
+		if o.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// obsolete 返回可删除的约束：模型未声明且名称在 drops 中列出，迁移或 SQL 文件建立的约束不动
+func (z tableConstraints) obsolete(expected tableConstraints, drops []string, withFK bool) (out tableConstraints) {
+	for _, c := range z {
+		if !slices.Contains(drops, c.Name) || expected.Find(c) || (c.Type == "f" && !withFK) {
+			continue
+		}
+		out = append(out, c)
+	}
+	return
+}
+
+const queryTableConstraints = `SELECT c.conname, c.contype,
+	ARRAY(SELECT a.attname FROM unnest(c.conkey) WITH ORDINALITY k(n, o)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.n ORDER BY k.o)::text[] AS columns,
+	coalesce(ft.relname, '') AS ref_table,
+	ARRAY(SELECT a.attname FROM unnest(c.confkey) WITH ORDINALITY k(n, o)
+		JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.n ORDER BY k.o)::text[] AS ref_columns
+FROM pg_constraint c
+JOIN pg_class t ON t.oid = c.conrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+LEFT JOIN pg_class ft ON ft.oid = c.confrelid
+WHERE n.nspname = ? AND t.relname = ? AND c.contype IN ('u', 'f')`
+
+func getTableIndexes(ctx context.Context, db IDB, schema, tbName string) (idxs tableIndexes, err error) {
+	err = db.NewSelect().Model(&idxs).
+		Where("schemaname=?", schema).
+		Where("tablename=?", tbName).
+		Scan(ctx)
+	if err == ErrNoRows {
+		err = nil
+	}
+	return
+}
+
+func getTableConstraints(ctx context.Context, db IDB, schema, tbName string) (cons tableConstraints, err error) {
+	err = db.NewRaw(queryTableConstraints, schema, tbName).Scan(ctx, &cons)
+	if err == ErrNoRows {
+		err = nil
+	}
+	return
+}
+
+// constraintName 与 PostgreSQL 默认命名一致: <table>_<columns>_<suffix>
+func constraintName(tbName string, cols []string, suffix string) string {
+	parts := make([]string, 0, len(cols)+2)
+	parts = append(parts, tbName)
+	for _, col := range cols {
+		parts = append(parts, strings.Trim(strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+				return r
+			}
+			if r >= 'A' && r <= 'Z' {
+				return r + 'a' - 'A'
+			}
+			return '_'
+		}, col), "_"))
+	}
+	parts = append(parts, suffix)
+	return strings.Join(parts, "_")
+}
+
+func fieldNames(fields []*schema.Field) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// modelConstraints 从 bun 标签的 unique 和关联得到模型声明的约束
+func modelConstraints(table *schema.Table, tbName string, withFK bool) (cons tableConstraints) {
+	keys := make([]string, 0, len(table.Unique))
+	for key := range table.Unique {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if key == "" {
+			for _, f := range table.Unique[key] {
+				cols := []string{f.Name}
+				cons = append(cons, tableConstraint{Name: constraintName(tbName, cols, "key"), Type: "u", Columns: cols})
+			}
+			continue
+		}
+		cons = append(cons, tableConstraint{Name: key, Type: "u", Columns: fieldNames(table.Unique[key])})
+	}
+
+	if !withFK {
+		return
+	}
+	keys = keys[:0]
+	for key := range table.Relations {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		rel := table.Relations[key]
+		if !rel.References() {
+			continue
+		}
+		cols := fieldNames(rel.BasePKs)
+		cons = append(cons, tableConstraint{
+			Name:       constraintName(tbName, cols, "fkey"),
+			Type:       "f",
+			Columns:    cols,
+			RefTable:   rel.JoinTable.Name,
+			RefColumns: fieldNames(rel.JoinPKs),
+			OnRule:     strings.TrimSpace(rel.OnUpdate + " " + rel.OnDelete),
+		})
+	}
+	return
+}
+
+func quoteIdents(names []string) string {
+	qs := make([]string, len(names))
+	for i, s := range names {
+		qs[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(qs, ", ")
+}
+
+func writeIndexSegment(output io.Writer, name, typ, schema string) (err error) {
+	if output != nil {
+		_, err = fmt.Fprintf(output, syncIndexSegment, name, typ, schema)
+	}
+	return
+}
+
+func dropIndexSQL(schema, name string, concurrent bool) string {
+	if concurrent {
+		return fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %q.%q;", schema, name)
+	}
+	return fmt.Sprintf("DROP INDEX IF EXISTS %q.%q;", schema, name)
+}
+
+func execIndexQuery(ctx context.Context, db IDB, output io.Writer, name, typ, schema string, query string) error {
+	if err := writeIndexSegment(output, name, typ, schema); err != nil {
+		return err
+	}
+	return execColumnQuery(ctx, db, output, "%s", query)
+}
+
+// syncModelIndexes 比对模型声明的索引、唯一约束和外键，trash schema 中只保留普通索引
+func syncModelIndexes(ctx context.Context, db IDB, schema, tbName string, model any, option alterOption) error {
+	trash := strings.HasSuffix(schema, crapSuffix)
+
+	idxs, err := getTableIndexes(ctx, db, schema, tbName)
+	if err != nil {
+		return err
+	}
+	cons, err := getTableConstraints(ctx, db, schema, tbName)
+	if err != nil {
+		return err
+	}
+
+	// 1. indexes
+	concurrent := concurrently(db)
+	var names []string
+	if ixr, ok := model.(Indexer); ok {
+		for _, d := range ixr.Indexes() {
+			if trash && d.Unique {
+				continue
+			}
+			name := d.IndexName(tbName)
+			names = append(names, name)
+			if option.add && !idxs.Has(name) {
+				if err = execIndexQuery(ctx, db, option.output, name, "INDEX", schema, d.createSQL(schema, tbName, concurrent)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	// 只删除模型明确列出的索引及约束，迁移或 SQL 文件建立的不动
+	if idr, ok := model.(IndexDropper); ok && option.drop {
+		drops := idr.DropIndexes()
+		for _, ti := range idxs {
+			if !slices.Contains(drops, ti.IndexName) || slices.Contains(names, ti.IndexName) ||
+				cons.HasName(ti.IndexName) || strings.HasSuffix(ti.IndexName, "_pkey") {
+				continue
+			}
+			query := dropIndexSQL(schema, ti.IndexName, concurrent)
+			if err = execIndexQuery(ctx, db, option.output, ti.IndexName, "INDEX", schema, query); err != nil {
+				return err
+			}
+		}
+	}
+
+	if trash {
+		return nil
+	}
+
+	// 2. unique and foreign key constraints
+	var withFK bool
+	if fk, ok := model.(ForeignKeyer); ok && fk.WithFK() {
+		withFK = true
+	}
+	table := db.Dialect().Tables().Get(reflect.TypeOf(model))
+	expected := modelConstraints(table, tbName, withFK)
+
+	if option.add {
+		for _, c := range expected {
+			if cons.Find(c) {
+				continue
+			}
+			var query string
+			if c.Type == "u" {
+				query = fmt.Sprintf("ALTER TABLE IF EXISTS %q.%q ADD CONSTRAINT %q UNIQUE (%s);",
+					schema, tbName, c.Name, quoteIdents(c.Columns))
+			} else {
+				rule := " NOT VALID"
+				if len(c.OnRule) > 0 {
+					rule = " " + c.OnRule + rule
+				}
+				query = fmt.Sprintf("ALTER TABLE IF EXISTS %q.%q ADD CONSTRAINT %q FOREIGN KEY (%s) REFERENCES %q.%q (%s)%s;",
+					schema, tbName, c.Name, quoteIdents(c.Columns), schema, c.RefTable, quoteIdents(c.RefColumns), rule)
+			}
+			if err = execIndexQuery(ctx, db, option.output, c.Name, "CONSTRAINT", schema, query); err != nil {
+				return err
+			}
+			if c.Type == "f" {
+				query = fmt.Sprintf("ALTER TABLE IF EXISTS %q.%q VALIDATE CONSTRAINT %q;", schema, tbName, c.Name)
+				if err = execColumnQuery(ctx, db, option.output, "%s", query); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if idr, ok := model.(IndexDropper); ok && option.drop {
+		for _, c := range cons.obsolete(expected, idr.DropIndexes(), withFK) {
+			query := fmt.Sprintf("ALTER TABLE IF EXISTS %q.%q DROP CONSTRAINT IF EXISTS %q;", schema, tbName, c.Name)
+			if err = execIndexQuery(ctx, db, option.output, c.Name, "CONSTRAINT", schema, query); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// trashIndexDef 把默认 schema 的索引定义改写到 trash schema
+//
+//	CREATE INDEX name ON sc.tb USING btree (col) => CREATE INDEX CONCURRENTLY IF NOT EXISTS name ON "trash"."tb" USING btree (col)
+func trashIndexDef(def, trashSchema, tbName string, concurrent bool) string {
+	const prefix = "CREATE INDEX "
+	on, using := strings.Index(def, " ON "), strings.Index(def, " USING ")
+	if !strings.HasPrefix(def, prefix) || on < len(prefix) || using < on {
+		return ""
+	}
+	create := "CREATE INDEX "
+	if concurrent {
+		create += "CONCURRENTLY "
+	}
+	return fmt.Sprintf("%sIF NOT EXISTS %s ON %q.%q%s;",
+		create, def[len(prefix):on], trashSchema, tbName, def[using:])
+}
+
+// syncTrashIndexes 将默认 schema 中的普通索引同步到 trash schema
+func syncTrashIndexes(ctx context.Context, db IDB, defSchema, trashSchema, tbName string, option alterOption) error {
+	defIdxs, err := getTableIndexes(ctx, db, defSchema, tbName)
+	if err != nil {
+		return err
+	}
+	trashIdxs, err := getTableIndexes(ctx, db, trashSchema, tbName)
+	if err != nil {
+		return err
+	}
+
+	concurrent := concurrently(db)
+	for _, ti := range defIdxs {
+		if trashIdxs.Has(ti.IndexName) {
+			continue
+		}
+		if query := trashIndexDef(ti.IndexDef, trashSchema, tbName, concurrent); len(query) > 0 {
+			if err = execIndexQuery(ctx, db, option.output, ti.IndexName, "INDEX", trashSchema, query); err != nil {
+				logger().LogAttrs(ctx, slog.LevelInfo, "add trash index fail",
+					slog.String("tbName", tbName),
+					slog.String("index", ti.IndexName),
+					slog.Any("err", err),
+				)
+				return err
+			}
+		}
+	}
+
+	if option.drop {
+		for _, ti := range trashIdxs {
+			if defIdxs.Has(ti.IndexName) || strings.HasSuffix(ti.IndexName, "_pkey") {
+				continue
+			}
+			query := dropIndexSQL(trashSchema, ti.IndexName, concurrent)
+			if err = execIndexQuery(ctx, db, option.output, ti.IndexName, "INDEX", trashSchema, query); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package pgx
 
-import "github.com/cupogo/andvari/models/comm"
+import (
+	"time"
+
+	"github.com/cupogo/andvari/models/comm"
+)
 
 type Model = comm.Model
 type Changeable = comm.Changeable
@@ -18,6 +22,11 @@ type Pager interface {
 	Sortable
 }
 
+type CursorPager interface {
+	comm.CursorPager
+	Sortable
+}
+
 type TextSearchable interface {
 	GetTsConfig() string
 	GetTsColumns() []string
@@ -28,10 +37,37 @@ type CreatedSetter interface {
 	SetCreated(ts any) bool
 }
 
+// Versioned enables optimistic locking in DoUpdate, see comm.VersionField
+type Versioned interface {
+	GetVersion() int
+	SetVersion(v int)
+}
+
 type ForeignKeyer interface {
 	WithFK() bool
 }
 
+// Indexer declares indexes maintained by AlterModel with WithAlterIndex
+type Indexer interface {
+	Indexes() []IndexDef
+}
+
+// IndexDropper names obsolete indexes and unique/foreign key constraints dropped by AlterModel
+// with WithAlterIndex and WithAlterDrop, other undeclared ones (e.g. from migrations or SQL files) are kept
+type IndexDropper interface {
+	DropIndexes() []string
+}
+
+// TrashRetainer declares how long deleted rows are kept in the trash schema, zero keeps forever
+type TrashRetainer interface {
+	TrashRetention() time.Duration
+}
+
+// CascadeDeleter names has-one/has-many relation fields whose rows move to trash with the model
+type CascadeDeleter interface {
+	CascadeRelations() []string
+}
+
 type Identitier interface {
 	IdentityLabel() string
 	IdentityModel() string
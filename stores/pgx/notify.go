@@ -0,0 +1,173 @@
+package pgx
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// DefaultChangeChannel 变更通知的默认频道
+const DefaultChangeChannel = "andvari_change"
+
+const maxChangeBackoff = 30 * time.Second
+
+// ChangeEvent 由 op_notify_change 触发器发出的行变更通知
+type ChangeEvent struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Op     string `json:"op"` // insert, update, delete
+	ID     string `json:"id"`
+
+	Operate OperateType `json:"-"`
+	Model   string      `json:"-"` // 注册模型的名称，同 ModelName
+}
+
+type ChangeHandler func(ctx context.Context, ev ChangeEvent)
+
+type changeOption struct {
+	channel string
+	resync  func(ctx context.Context)
+}
+
+type ChangeOption func(opt *changeOption)
+
+// WithChangeChannel 使用指定的频道，默认 DefaultChangeChannel
+func WithChangeChannel(ch string) ChangeOption {
+	return func(opt *changeOption) {
+		if len(ch) > 0 {
+			opt.channel = ch
+		}
+	}
+}
+
+// WithChangeResync 断线重连后调用，断线期间的通知会丢失，可在此重新同步
+func WithChangeResync(fn func(ctx context.Context)) ChangeOption {
+	return func(opt *changeOption) {
+		opt.resync = fn
+	}
+}
+
+func decodeChange(payload string, names map[string]string) (ev ChangeEvent, err error) {
+	if err = json.Unmarshal([]byte(payload), &ev); err != nil {
+		return
+	}
+	switch ev.Op {
+	case "insert":
+		ev.Operate = OperateTypeCreate
+	case "update":
+		ev.Operate = OperateTypeUpdate
+	case "delete":
+		ev.Operate = OperateTypeDelete
+	}
+	ev.Model = names[ev.Table]
+	return
+}
+
+// registeredModel 返回已注册的同类型模型
+func registeredModel(model any) (any, bool) {
+	typ := reflect.TypeOf(model)
+	for _, m := range allmodels {
+		if reflect.TypeOf(m) == typ {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// EnableChangeNotify 为已注册的模型安装变更通知触发器，channel 为空时使用 DefaultChangeChannel
+func (w *DB) EnableChangeNotify(ctx context.Context, channel string, models ...any) error {
+	if len(channel) == 0 {
+		channel = DefaultChangeChannel
+	}
+	scDft, _ := w.schemas(ctx)
+	for _, model := range models {
+		if _, ok := registeredModel(model); !ok {
+			return ErrInvalidArgs
+		}
+		table := getTableName(w.DB, model)
+		if _, err := w.NewRaw("SELECT op_notify_install(?, ?, ?)", scDft, table, channel).Exec(ctx); err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "install notify fail",
+				slog.String("table", table),
+				slog.Any("err", err),
+			)
+			return err
+		}
+	}
+	return nil
+}
+
+// DisableChangeNotify 移除模型的变更通知触发器
+func (w *DB) DisableChangeNotify(ctx context.Context, models ...any) error {
+	scDft, _ := w.schemas(ctx)
+	for _, model := range models {
+		table := getTableName(w.DB, model)
+		if _, err := w.NewRaw("SELECT op_notify_uninstall(?, ?)", scDft, table).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListenChanges 接收变更通知直到 ctx 结束，连接断开时自动重连
+func (w *DB) ListenChanges(ctx context.Context, fn ChangeHandler, opts ...ChangeOption) error {
+	opt := &changeOption{channel: DefaultChangeChannel}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	names := make(map[string]string, len(allmodels))
+	for _, m := range allmodels {
+		names[getTableName(w.DB, m)] = ModelName(m)
+	}
+
+	ln := pgdriver.NewListener(w.DB)
+	defer func() { _ = ln.Close() }()
+	if err := ln.Listen(ctx, opt.channel); err != nil {
+		return err
+	}
+
+	var backoff time.Duration
+	for {
+		_, payload, err := ln.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			backoff = min(max(2*backoff, time.Second), maxChangeBackoff)
+			logger().LogAttrs(ctx, slog.LevelInfo, "receive change fail",
+				slog.String("channel", opt.channel),
+				slog.Duration("backoff", backoff),
+				slog.Any("err", err),
+			)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			// 换用新的 listener，避免在原 listener 上重复登记频道
+			_ = ln.Close()
+			ln = pgdriver.NewListener(w.DB)
+			if err = ln.Listen(ctx, opt.channel); err == nil {
+				backoff = 0
+				if opt.resync != nil {
+					opt.resync(ctx)
+				}
+			}
+			continue
+		}
+
+		ev, err := decodeChange(payload, names)
+		if err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "decode change fail",
+				slog.String("payload", payload),
+				slog.Any("err", err),
+			)
+			continue
+		}
+		fn(ctx, ev)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/cupogo/andvari/models/field"
@@ -79,7 +80,18 @@ func CreateModel(ctx context.Context, db IDB, model any, dropIt bool) (err error
 	return
 }
 
-func ApplyQuerySort(p Sortable, q *SelectQuery) *SelectQuery {
+// sortRule 单个排序字段及方向
+type sortRule struct {
+	key string
+	op  string // ASC, DESC or empty
+}
+
+func (r sortRule) desc() bool {
+	return r.op == "DESC"
+}
+
+// parseSortRules 解析排序参数，仅保留可排序的字段
+func parseSortRules(p Sortable) (rules []sortRule) {
 	if rule := p.GetSort(); len(rule) > 1 {
 		for _, order := range strings.Split(rule, ",") {
 			var key, op string
@@ -95,19 +107,36 @@ func ApplyQuerySort(p Sortable, q *SelectQuery) *SelectQuery {
 				key = order
 			}
 			if len(key) > 0 && p.CanSort(key) {
-				if len(op) > 0 {
-					q.OrderExpr(key + " " + op)
-				} else {
-					q.OrderExpr(key)
-				}
+				rules = append(rules, sortRule{key: key, op: op})
 			}
 		}
 	}
+	return
+}
+
+func ApplyQuerySort(p Sortable, q *SelectQuery) *SelectQuery {
+	for _, r := range parseSortRules(p) {
+		if len(r.op) > 0 {
+			q.OrderExpr(r.key + " " + r.op)
+		} else {
+			q.OrderExpr(r.key)
+		}
+	}
 	return q
 }
 
-// QueryPager 根据分页参数进行查询
+// QueryPager 根据分页参数进行查询，CursorPager 在未指定 skip 时使用游标分页，
+// 按相关度排序(见 TextSearchSpec.SetTsRank)时不使用游标
 func QueryPager(ctx context.Context, p Pager, q *SelectQuery) (count int, err error) {
+	if cp, ok := p.(CursorPager); ok && cp.GetLimit() >= 0 &&
+		(len(cp.GetCursor()) > 0 || cp.GetSkip() == 0) {
+		if tr, ok := p.(interface{ tsRanked() bool }); !ok || !tr.tsRanked() {
+			return QueryCursor(ctx, cp, q)
+		}
+		if len(cp.GetCursor()) > 0 {
+			return 0, ErrInvalidArgs
+		}
+	}
 	q = ApplyQuerySort(p, q)
 	limit := p.GetLimit()
 	if p.GetPage() > 0 && limit == 0 {
@@ -167,7 +196,15 @@ func ModelWithPK(ctx context.Context, db IDB, obj Model, columns ...string) (err
 
 func ModelWithPKID(ctx context.Context, db IDB, obj Model, id any, columns ...string) error {
 	if obj.SetID(id) {
-		return ModelWithPK(ctx, db, obj, columns...)
+		c, key, ttl := modelCacheFor(ctx, db, obj, columns)
+		if c != nil && loadCached(ctx, c, key, obj) {
+			return nil
+		}
+		err := ModelWithPK(ctx, db, obj, columns...)
+		if err == nil && c != nil {
+			storeCached(ctx, c, key, obj, ttl)
+		}
+		return err
 	}
 
 	logger().LogAttrs(ctx, slog.LevelInfo, "invalid id",
@@ -217,36 +254,31 @@ func ModelWith(ctx context.Context, db IDB, obj Model, key, op string, val any,
 
 // DoInsert insert with ignore duplicate (optional)
 func DoInsert(ctx context.Context, db IDB, obj Model, args ...any) error {
+	if ok, err := sessionTx(ctx, db, func(ctx context.Context, tx IDB) error {
+		return DoInsert(ctx, tx, obj, args...)
+	}); ok {
+		return err
+	}
 	isZeroID := obj.IsZeroID()
 	// Call to saving hook
 	if err := TryToBeforeCreateHooks(ctx, obj); err != nil {
 		return err
 	}
 
-	if dtf, ok := obj.(CreatedSetter); ok {
-		if ts, ok := CreatedFromContext(ctx); ok && ts > 0 {
-			if dtf.SetCreated(ts) {
-				logger().LogAttrs(ctx, slog.LevelInfo, "set created ok",
-					slog.Any("ts", ts),
-				)
-			} else {
-				logger().LogAttrs(ctx, slog.LevelInfo, "set created fail",
-					slog.Any("ts", ts),
-				)
-			}
-		}
-	}
+	applyCreatedFromContext(ctx, obj)
+	applyScopeOnCreate(ctx, obj)
 
 	name := ModelName(obj)
 	q := db.NewInsert().Model(obj)
 	if tso, ok := obj.(TextSearchable); ok {
 		cfg := tso.GetTsConfig()
+		dftCfg, ftsOk := ftsOf(ctx, db)
 		if len(cfg) == 0 {
-			if cfg = LastFTSConfig(); len(cfg) > 0 {
+			if cfg = dftCfg; len(cfg) > 0 {
 				q.Value("ts_cfg", "?", cfg)
 			}
 		}
-		if LastFTSEnabled() {
+		if ftsOk {
 			if ktg, ok := tso.(KeywordTextGetter); ok {
 				if txt := ktg.GetKeywordText(); len(txt) > 0 {
 					if vck, ok := tso.(IColumnKeyword); ok {
@@ -265,26 +297,7 @@ func DoInsert(ctx context.Context, db IDB, obj Model, args ...any) error {
 		}
 	}
 	argc := len(args)
-	if argc > 0 {
-		unikey := field.ID
-		if k, ok := args[0].(string); ok && isZeroID {
-			unikey = k
-			args = args[1:]
-		}
-		q.On("CONFLICT (?) DO UPDATE", Ident(unikey))
-		var foundUpd bool
-		for _, arg := range args {
-			if a, ok := arg.(string); ok {
-				q.Set("?0 = EXCLUDED.?0", Ident(a))
-				if a == field.Updated {
-					foundUpd = true
-				}
-			}
-		}
-		if !foundUpd {
-			q.Set("?0 = EXCLUDED.?0", Ident(field.Updated))
-		}
-	}
+	applyInsertConflict(q, isZeroID, args)
 	q.Returning(field.ID)
 
 	if _, err := q.Exec(ctx); err != nil {
@@ -303,11 +316,61 @@ func DoInsert(ctx context.Context, db IDB, obj Model, args ...any) error {
 	)
 
 	dbLogModelOp(ctx, db, OperateTypeCreate, obj, argc == 0)
+	if argc > 0 {
+		// 冲突时可能更新了已有的记录
+		invalidateCached(ctx, db, obj)
+	}
+
+	return tryAfterHooks(ctx, obj, TryToAfterCreateHooksX)
+}
+
+func applyCreatedFromContext(ctx context.Context, obj Model) {
+	if dtf, ok := obj.(CreatedSetter); ok {
+		if ts, ok := CreatedFromContext(ctx); ok && ts > 0 {
+			if dtf.SetCreated(ts) {
+				logger().LogAttrs(ctx, slog.LevelInfo, "set created ok",
+					slog.Any("ts", ts),
+				)
+			} else {
+				logger().LogAttrs(ctx, slog.LevelInfo, "set created fail",
+					slog.Any("ts", ts),
+				)
+			}
+		}
+	}
+}
 
-	return TryToAfterCreateHooks(obj)
+// applyInsertConflict 按 args 追加 ON CONFLICT DO UPDATE，args[0] 为空主键时的唯一键，其余为更新的列
+func applyInsertConflict(q *InsertQuery, isZeroID bool, args []any) {
+	if len(args) == 0 {
+		return
+	}
+	unikey := field.ID
+	if k, ok := args[0].(string); ok && isZeroID {
+		unikey = k
+		args = args[1:]
+	}
+	q.On("CONFLICT (?) DO UPDATE", Ident(unikey))
+	var foundUpd bool
+	for _, arg := range args {
+		if a, ok := arg.(string); ok {
+			q.Set("?0 = EXCLUDED.?0", Ident(a))
+			if a == field.Updated {
+				foundUpd = true
+			}
+		}
+	}
+	if !foundUpd {
+		q.Set("?0 = EXCLUDED.?0", Ident(field.Updated))
+	}
 }
 
 func DoUpdate(ctx context.Context, db IDB, obj Model, columns ...string) error {
+	if ok, err := sessionTx(ctx, db, func(ctx context.Context, tx IDB) error {
+		return DoUpdate(ctx, tx, obj, columns...)
+	}); ok {
+		return err
+	}
 
 	if vo, ok := obj.(IsUpdateSetter); ok && !vo.IsUpdate() {
 		vo.SetIsUpdate(true)
@@ -344,15 +407,27 @@ func DoUpdate(ctx context.Context, db IDB, obj Model, columns ...string) error {
 		return nil
 	}
 
+	// 乐观锁: 校验旧版本号并递增
+	vo, versioned := obj.(Versioned)
+	var version int
+	if versioned {
+		version = vo.GetVersion()
+		vo.SetVersion(version + 1)
+		if !slices.Contains(columns, field.Version) {
+			columns = append(columns[:len(columns):len(columns)], field.Version)
+		}
+	}
+
 	q := db.NewUpdate().Model(obj).Column(columns...)
 	if tso, ok := obj.(TextSearchable); ok {
 		cfg := tso.GetTsConfig()
+		dftCfg, ftsOk := ftsOf(ctx, db)
 		if len(cfg) == 0 {
-			if cfg = LastFTSConfig(); len(cfg) > 0 {
+			if cfg = dftCfg; len(cfg) > 0 {
 				q.Column("ts_cfg").Value("ts_cfg", "?", cfg)
 			}
 		}
-		if LastFTSEnabled() {
+		if ftsOk {
 			if ktg, ok := tso.(KeywordTextGetter); ok {
 				if txt := ktg.GetKeywordText(); len(txt) > 0 {
 					if vck, ok := tso.(IColumnKeyword); ok {
@@ -382,7 +457,16 @@ func DoUpdate(ctx context.Context, db IDB, obj Model, columns ...string) error {
 		}
 	}
 
-	if _, err := q.WherePK().Exec(ctx); err != nil {
+	q.WherePK()
+	if versioned {
+		q.Where("?TableAlias.? = ?", Ident(field.Version), version)
+	}
+	scoped := applyScope(ctx, obj, q.QueryBuilder())
+	res, err := q.Exec(ctx)
+	if err != nil {
+		if versioned {
+			vo.SetVersion(version)
+		}
 		logger().LogAttrs(ctx, slog.LevelInfo, "update fail",
 			slog.String("name", name),
 			slog.Any("obj", obj),
@@ -391,14 +475,34 @@ func DoUpdate(ctx context.Context, db IDB, obj Model, columns ...string) error {
 		)
 		return fmt.Errorf("update %s fail: %w", name, err)
 	}
+	if n, _ := res.RowsAffected(); n == 0 && (versioned || scoped) {
+		if versioned {
+			vo.SetVersion(version)
+		}
+		if scoped {
+			// 不在访问范围内
+			if err = checkScope(ctx, db, obj); err != nil {
+				return fmt.Errorf("update %s fail: %w", name, err)
+			}
+		}
+		if versioned {
+			logger().LogAttrs(ctx, slog.LevelInfo, "update conflict",
+				slog.String("name", name),
+				slog.Any("id", obj.GetID()),
+				slog.Int("version", version),
+			)
+			return fmt.Errorf("update %s fail: %w", name, ErrConflict)
+		}
+	}
 
 	logger().LogAttrs(ctx, slog.LevelDebug, "update ok",
 		slog.String("name", name),
 		slog.Any("id", obj.GetID()),
 		slog.Any("columns", columns),
 	)
+	invalidateCached(ctx, db, obj)
 
-	if err := TryToAfterUpdateHooks(obj); err != nil {
+	if err := tryAfterHooks(ctx, obj, TryToAfterUpdateHooksX); err != nil {
 		return err
 	}
 
@@ -466,6 +570,12 @@ func StoreWithSet[P ModelSetPtr[T, U], T any, U any](ctx context.Context, db IDB
 	}
 
 	obj.SetWith(in)
+	// 以输入中的版本号校验
+	if vi, ok := any(in).(interface{ GetVersion() int }); ok && exist && vi.GetVersion() > 0 {
+		if vo, ok := any(obj).(Versioned); ok {
+			vo.SetVersion(vi.GetVersion())
+		}
+	}
 
 	DoMetaUp(ctx, db, obj)
 
@@ -485,16 +595,25 @@ func DoMetaUp(ctx context.Context, db IDB, obj Model) {
 }
 
 func DoDelete(ctx context.Context, db IDB, table string, _id any) error {
-	return DoDeleteT(ctx, db, LastSchema(), LastSchemaCrap(), table, _id)
+	scDft, scCrap := schemasOf(ctx, db)
+	return DoDeleteT(ctx, db, scDft, scCrap, table, _id)
 }
 
 func DoDeleteM(ctx context.Context, db IDB, scDft, scCrap string, obj ModelIdentity) error {
 	if obj.IsZeroID() {
 		return ErrEmptyPK
 	}
+	if err := checkScope(ctx, db, obj); err != nil {
+		return err
+	}
+	if err := TryToBeforeDeleteHooks(ctx, obj); err != nil {
+		return err
+	}
 	err := DoDeleteT(ctx, db, scDft, scCrap, obj.IdentityTable(), obj.GetID())
 	if err == nil {
 		dbLogModelOp(ctx, db, OperateTypeDelete, obj)
+		invalidateCached(ctx, db, obj)
+		err = tryAfterHooks(ctx, obj, TryToAfterDeleteHooks)
 	}
 	return err
 }
@@ -520,7 +639,9 @@ func OpDeleteInTrans(ctx context.Context, db IDB, scDft, scCrap string, tOrQ any
 // DoDeleteT call sp to do affect delete with table and id // TODO: id as int64
 func DoDeleteT(ctx context.Context, db IDB, scDft, scCrap string, table string, _id any) error {
 	var ret int
-	err := db.NewRaw("SELECT op_affect_delete(?, ?, ?, ?)", scDft, scCrap, table, _id).Scan(ctx, &ret)
+	err := runWithDeleteMeta(ctx, db, func(ctx context.Context, db IDB) error {
+		return db.NewRaw("SELECT op_affect_delete(?, ?, ?, ?)", scDft, scCrap, table, _id).Scan(ctx, &ret)
+	})
 	if err != nil {
 		logger().LogAttrs(ctx, slog.LevelInfo, "delete fail",
 			slog.String("table", table),
@@ -603,16 +724,15 @@ func BatchDeleteWithKey(ctx context.Context, db IDB, name, key string, id oid.OI
 	var hold idsHold
 	if err = db.NewRaw("SELECT array_agg(id) as ids FROM ? WHERE ? = ?", Ident(name), Ident(key), id).Scan(ctx, &hold); err == nil {
 		ids = hold.IDs
-		for _, id := range ids {
-			if err = DoDelete(ctx, db, name, id); err != nil {
-				logger().LogAttrs(ctx, slog.LevelInfo, "delete fail",
-					slog.String("name", name),
-					slog.String("key", key),
-					slog.Any("id", id),
-					slog.Any("err", err),
-				)
-				return
-			}
+		scDft, scCrap := schemasOf(ctx, db)
+		if _, err = DoDeleteBatchT(ctx, db, scDft, scCrap, name, ids); err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "delete fail",
+				slog.String("name", name),
+				slog.String("key", key),
+				slog.Any("id", id),
+				slog.Any("err", err),
+			)
+			return
 		}
 		if len(ids) > 0 {
 			logger().LogAttrs(ctx, slog.LevelInfo, "batch delete done",
@@ -732,6 +852,7 @@ func QueryOne(db IDB, obj Model, args ...any) (*SelectQuery, bool) {
 // QueryList Query as a collection list with a Sifter
 func QueryList(ctx context.Context, db IDB, spec Sifter, dataptr any) *SelectQuery {
 	q := db.NewSelect().Model(dataptr)
+	applyScope(ctx, dataptr, q.QueryBuilder())
 	if v, ok := spec.(SifterX); ok {
 		q = v.SiftX(ctx, q)
 	}
@@ -759,13 +880,16 @@ func ApplyQueryContext(ctx context.Context, q *SelectQuery) *SelectQuery {
 // n := Count(ctx, db, (*User)(nil), "type = 1")
 // n := Count(ctx, db, (*User)(nil), "status = ?", "active")
 func Count(ctx context.Context, db IDB, obj Model, args ...any) (count int) {
-	q := db.NewSelect().Model(obj)
-	if len(args) > 0 {
-		if s, ok := args[0].(string); ok {
-			q.Where(s, args[1:]...)
+	_ = sessionReadOf(ctx, db, func(ctx context.Context, db IDB) (err error) {
+		q := db.NewSelect().Model(obj)
+		if len(args) > 0 {
+			if s, ok := args[0].(string); ok {
+				q.Where(s, args[1:]...)
+			}
 		}
-	}
-	count, _ = q.Count(ctx)
+		count, err = q.Count(ctx)
+		return
+	})
 	return
 }
 
@@ -815,10 +939,14 @@ func EnsureID(ctx context.Context, db IDB, obj Model, args ...any) error {
 // model object, and arguments. If the Query cannot be successfully formed (as indicated by 'ok' being false),
 // it returns 'false' along with an ErrInvalidArgs error indicating faulty or insufficient input parameters.
 // If the Query formation is successful, it proceeds to check whether the record exists in the database context specified.
-func Exists(ctx context.Context, db IDB, obj Model, args ...any) (bool, error) {
-	q, ok := QueryOne(db, obj, args...)
-	if !ok {
-		return false, ErrInvalidArgs // Return an error if arguments do not form a valid query
-	}
-	return q.Exists(ctx)
+func Exists(ctx context.Context, db IDB, obj Model, args ...any) (exists bool, err error) {
+	err = sessionReadOf(ctx, db, func(ctx context.Context, db IDB) (err error) {
+		q, ok := QueryOne(db, obj, args...)
+		if !ok {
+			return ErrInvalidArgs // Return an error if arguments do not form a valid query
+		}
+		exists, err = q.Exists(ctx)
+		return
+	})
+	return
 }
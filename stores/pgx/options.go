@@ -0,0 +1,229 @@
+package pgx
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/extra/bunotel"
+
+	"github.com/cupogo/andvari/utils"
+)
+
+// openOptions 连接选项，默认值取自环境变量 PGX_MAX_OPEN_X、PGX_BUN_OTEL、PGX_QUERY_DEBUG
+type openOptions struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
+
+	stmtTimeout time.Duration
+	appName     string
+	searchPath  string
+	ftsCfg      string
+
+	otel     int // 0 关闭, 1 开启, 2 开启并记录格式化的语句
+	debug    int // 0 关闭, 1 记录出错的语句, 2 记录全部语句
+	hooks    []bun.QueryHook
+	replicas []string
+
+	tenantSchema TenantSchemaFunc
+	rls          bool
+	cache        ModelCache
+}
+
+type OpenOption func(opt *openOptions)
+
+func newOpenOptions(opts ...OpenOption) *openOptions {
+	opt := &openOptions{}
+	if s, ok := os.LookupEnv("PGX_MAX_OPEN_X"); ok && len(s) > 0 {
+		if x, err := strconv.Atoi(s); err == nil && x > 0 && x <= 4 {
+			opt.maxOpen = x * runtime.GOMAXPROCS(0)
+			opt.maxIdle = opt.maxOpen
+		}
+	}
+	if s, ok := os.LookupEnv("PGX_BUN_OTEL"); ok && (s == "1" || s == "2") {
+		opt.otel, _ = strconv.Atoi(s)
+	}
+	if s, ok := os.LookupEnv("PGX_QUERY_DEBUG"); ok && len(s) > 0 {
+		if x, err := strconv.ParseInt(s, 10, 32); err == nil && x > 0 {
+			opt.debug = min(int(x), 2)
+		}
+	}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	return opt
+}
+
+// WithMaxOpenConns 最大连接数，同时作为默认的最大空闲连接数
+func WithMaxOpenConns(n int) OpenOption {
+	return func(opt *openOptions) {
+		opt.maxOpen = n
+		if opt.maxIdle == 0 || opt.maxIdle > n {
+			opt.maxIdle = n
+		}
+	}
+}
+
+// WithMaxIdleConns 最大空闲连接数
+func WithMaxIdleConns(n int) OpenOption {
+	return func(opt *openOptions) {
+		opt.maxIdle = n
+	}
+}
+
+// WithConnMaxLifetime 连接的最长使用时间
+func WithConnMaxLifetime(d time.Duration) OpenOption {
+	return func(opt *openOptions) {
+		opt.maxLifetime = d
+	}
+}
+
+// WithConnMaxIdleTime 连接的最长空闲时间
+func WithConnMaxIdleTime(d time.Duration) OpenOption {
+	return func(opt *openOptions) {
+		opt.maxIdleTime = d
+	}
+}
+
+// WithStatementTimeout 会话参数 statement_timeout
+func WithStatementTimeout(d time.Duration) OpenOption {
+	return func(opt *openOptions) {
+		opt.stmtTimeout = d
+	}
+}
+
+// WithApplicationName 会话参数 application_name
+func WithApplicationName(name string) OpenOption {
+	return func(opt *openOptions) {
+		opt.appName = name
+	}
+}
+
+// WithSearchPath 会话参数 search_path，第一个 schema 作为默认 schema，未设置时为用户名
+func WithSearchPath(schemas ...string) OpenOption {
+	return func(opt *openOptions) {
+		opt.searchPath = strings.Join(schemas, ", ")
+	}
+}
+
+// WithFTSConfig 全文检索配置，如 zhcfg
+func WithFTSConfig(cfg string) OpenOption {
+	return func(opt *openOptions) {
+		opt.ftsCfg = cfg
+	}
+}
+
+// WithOTEL 0 关闭, 1 开启 OpenTelemetry, 2 开启并记录格式化的语句，默认取 PGX_BUN_OTEL
+func WithOTEL(mode int) OpenOption {
+	return func(opt *openOptions) {
+		opt.otel = mode
+	}
+}
+
+// WithDebug 0 关闭, 1 输出出错的语句, 2 输出全部语句，默认取 PGX_QUERY_DEBUG
+func WithDebug(level int) OpenOption {
+	return func(opt *openOptions) {
+		opt.debug = level
+	}
+}
+
+// WithQueryHook 添加自定义的 bun.QueryHook
+func WithQueryHook(hooks ...bun.QueryHook) OpenOption {
+	return func(opt *openOptions) {
+		opt.hooks = append(opt.hooks, hooks...)
+	}
+}
+
+// WithReplicas 只读副本，见 DB.AddReplicas
+func WithReplicas(dsns ...string) OpenOption {
+	return func(opt *openOptions) {
+		opt.replicas = append(opt.replicas, dsns...)
+	}
+}
+
+// defaultSchema search_path 中的第一个 schema
+func (opt *openOptions) defaultSchema() string {
+	first, _, _ := strings.Cut(opt.searchPath, ",")
+	first = strings.Trim(strings.TrimSpace(first), `"`)
+	if first == "$user" {
+		return ""
+	}
+	return first
+}
+
+func (opt *openOptions) driverOptions() []pgdriver.Option {
+	var dos []pgdriver.Option
+	if len(opt.appName) > 0 {
+		dos = append(dos, pgdriver.WithApplicationName(opt.appName))
+	}
+	params := map[string]any{}
+	if opt.stmtTimeout > 0 {
+		params["statement_timeout"] = opt.stmtTimeout.Milliseconds()
+	}
+	if len(opt.searchPath) > 0 {
+		params["search_path"] = opt.searchPath
+	}
+	if len(params) > 0 {
+		// 合并 DSN 中的参数
+		dos = append(dos, func(cfg *pgdriver.Config) {
+			if cfg.ConnParams == nil {
+				cfg.ConnParams = make(map[string]any, len(params))
+			}
+			for k, v := range params {
+				cfg.ConnParams[k] = v
+			}
+		})
+	}
+	return dos
+}
+
+func (opt *openOptions) applyPool(sqldb *sql.DB) {
+	if opt.maxOpen > 0 {
+		sqldb.SetMaxOpenConns(opt.maxOpen)
+		logger().LogAttrs(context.Background(), slog.LevelDebug, "set max open",
+			slog.Int("n", opt.maxOpen),
+		)
+	}
+	if opt.maxIdle > 0 {
+		sqldb.SetMaxIdleConns(opt.maxIdle)
+	}
+	if opt.maxLifetime > 0 {
+		sqldb.SetConnMaxLifetime(opt.maxLifetime)
+	}
+	if opt.maxIdleTime > 0 {
+		sqldb.SetConnMaxIdleTime(opt.maxIdleTime)
+	}
+}
+
+func (opt *openOptions) applyHooks(db *bun.DB, dbname string) {
+	if opt.otel == 1 || opt.otel == 2 {
+		db.WithQueryHook(bunotel.NewQueryHook(
+			bunotel.WithDBName(dbname),
+			bunotel.WithFormattedQueries(opt.otel == 2),
+			bunotel.WithSpanNameQueryGen(func(qe *bun.QueryEvent) string {
+				if qe.IQuery != nil {
+					if tableName := qe.IQuery.GetTableName(); tableName != "" {
+						return qe.Operation() + " " + utils.CamelCased(tableName, false)
+					}
+				}
+
+				return qe.Operation()
+			}),
+		))
+	}
+	if opt.debug > 0 {
+		db.WithQueryHook(&DebugHook{Verbose: opt.debug > 1})
+	}
+	for _, h := range opt.hooks {
+		db.WithQueryHook(h)
+	}
+}
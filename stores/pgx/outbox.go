@@ -0,0 +1,239 @@
+package pgx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cupogo/andvari/models/comm"
+)
+
+const (
+	defaultOutboxBatch       = 100
+	defaultOutboxInterval    = time.Second
+	defaultOutboxMaxAttempts = 10
+	maxOutboxBackoff         = time.Hour
+)
+
+// OutboxMessage 发件箱中待投递的消息，表由 pg_16_table_outbox.sql 创建
+type OutboxMessage struct {
+	comm.BaseModel `bun:"table:op_outbox,alias:ob" json:"-"`
+
+	ID int64 `bun:"id,pk,autoincrement" json:"id"`
+	// 主题，如 field.AccountSignedIn
+	Topic string `bun:"topic,notnull" json:"topic"`
+	// 消息键，用于分区或去重
+	Key string `bun:"key,notnull" json:"key,omitempty"`
+	// 消息内容
+	Payload json.RawMessage `bun:"payload,notnull,type:jsonb" json:"payload"`
+	// 登记时间
+	Created time.Time `bun:"created,notnull,default:now()" json:"created"`
+	// 已尝试投递次数
+	Attempts int `bun:"attempts,notnull" json:"attempts"`
+	// 下次投递时间
+	NextAt time.Time `bun:"next_at,notnull,default:now()" json:"nextAt"`
+	// 投递成功时间
+	DeliveredAt *time.Time `bun:"delivered_at" json:"deliveredAt,omitempty"`
+	// 最后一次投递的错误
+	LastError string `bun:"last_error,notnull" json:"lastError,omitempty"`
+}
+
+type OutboxMessages []OutboxMessage
+
+// Enqueue 登记一条消息，应与模型写入使用同一个 Tx，payload 为 json.RawMessage、[]byte 或可序列化的值
+func Enqueue(ctx context.Context, db IDB, topic, key string, payload any) error {
+	if len(topic) == 0 {
+		return ErrInvalidArgs
+	}
+	var data json.RawMessage
+	switch v := payload.(type) {
+	case json.RawMessage:
+		data = v
+	case []byte:
+		data = v
+	default:
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("marshal outbox payload fail: %w", err)
+		}
+		data = b
+	}
+	msg := &OutboxMessage{Topic: topic, Key: key, Payload: data}
+	if _, err := db.NewInsert().Model(msg).
+		ExcludeColumn("created", "next_at", "delivered_at").Returning("id").Exec(ctx); err != nil {
+		logger().LogAttrs(ctx, slog.LevelInfo, "enqueue outbox fail",
+			slog.String("topic", topic),
+			slog.String("key", key),
+			slog.Any("err", err),
+		)
+		return fmt.Errorf("enqueue outbox fail: %w", err)
+	}
+	return nil
+}
+
+// OutboxEventHandler 将模型事件登记到发件箱，topicOf 返回空时忽略，
+// 以同步方式 Subscribe 时与模型写入在同一事务中
+//
+//	Subscribe(OutboxEventHandler(func(ev ModelEvent) string {
+//		return "cms." + ev.Model + "." + ev.Operate.String()
+//	}), WithModels("Article"))
+func OutboxEventHandler(topicOf func(ev ModelEvent) string) EventHandler {
+	return func(ctx context.Context, db IDB, ev ModelEvent) error {
+		topic := topicOf(ev)
+		if len(topic) == 0 {
+			return nil
+		}
+		return Enqueue(ctx, db, topic, fmt.Sprint(ev.Obj.GetID()), ev.Obj)
+	}
+}
+
+// OutboxPublisher 投递一条消息到消息服务，返回错误时稍后重试
+type OutboxPublisher func(ctx context.Context, msg *OutboxMessage) error
+
+type OutboxOption func(p *OutboxPoller)
+
+// WithOutboxBatch 每轮最多领取的消息数，默认 100
+func WithOutboxBatch(n int) OutboxOption {
+	return func(p *OutboxPoller) {
+		if n > 0 {
+			p.batch = n
+		}
+	}
+}
+
+// WithOutboxInterval 没有消息时的轮询间隔，默认 1s
+func WithOutboxInterval(d time.Duration) OutboxOption {
+	return func(p *OutboxPoller) {
+		if d > 0 {
+			p.interval = d
+		}
+	}
+}
+
+// WithOutboxMaxAttempts 最多投递次数，超过后不再领取，默认 10
+func WithOutboxMaxAttempts(n int) OutboxOption {
+	return func(p *OutboxPoller) {
+		if n > 0 {
+			p.maxAttempts = n
+		}
+	}
+}
+
+// OutboxPoller 以 FOR UPDATE SKIP LOCKED 领取待投递的消息，可多实例并行
+type OutboxPoller struct {
+	db  IDB
+	pub OutboxPublisher
+
+	batch       int
+	interval    time.Duration
+	maxAttempts int
+}
+
+func NewOutboxPoller(db IDB, pub OutboxPublisher, opts ...OutboxOption) *OutboxPoller {
+	p := &OutboxPoller{
+		db:          db,
+		pub:         pub,
+		batch:       defaultOutboxBatch,
+		interval:    defaultOutboxInterval,
+		maxAttempts: defaultOutboxMaxAttempts,
+	}
+	for _, fn := range opts {
+		fn(p)
+	}
+	return p
+}
+
+// Run 持续投递直到 ctx 结束
+func (p *OutboxPoller) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		n, err := p.Poll(ctx)
+		if err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "poll outbox fail",
+				slog.Any("err", err),
+			)
+		}
+		if n < p.batch || err != nil {
+			timer.Reset(p.interval)
+		} else {
+			timer.Reset(0)
+		}
+	}
+}
+
+// Poll 领取并投递一批消息，返回领取的数量
+func (p *OutboxPoller) Poll(ctx context.Context) (n int, err error) {
+	err = p.db.RunInTx(ctx, nil, func(ctx context.Context, tx Tx) error {
+		var data OutboxMessages
+		err := tx.NewSelect().Model(&data).
+			Where("delivered_at IS NULL").
+			Where("next_at <= now()").
+			Where("attempts < ?", p.maxAttempts).
+			OrderExpr("id").Limit(p.batch).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		n = len(data)
+		for i := range data {
+			if err := p.deliver(ctx, tx, &data[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (p *OutboxPoller) deliver(ctx context.Context, tx Tx, msg *OutboxMessage) error {
+	q := tx.NewUpdate().Model(msg).WherePK()
+	if perr := p.pub(ctx, msg); perr != nil {
+		msg.Attempts++
+		msg.NextAt = time.Now().Add(outboxBackoff(msg.Attempts))
+		msg.LastError = perr.Error()
+		logger().LogAttrs(ctx, slog.LevelInfo, "publish outbox fail",
+			slog.Int64("id", msg.ID),
+			slog.String("topic", msg.Topic),
+			slog.Int("attempts", msg.Attempts),
+			slog.Any("err", perr),
+		)
+		q.Column("attempts", "next_at", "last_error")
+	} else {
+		now := time.Now()
+		msg.Attempts++
+		msg.DeliveredAt = &now
+		q.Column("attempts", "delivered_at")
+	}
+	_, err := q.Exec(ctx)
+	return err
+}
+
+// outboxBackoff 第 n 次失败后的等待时间，按 2^n 秒增长，最长 1 小时
+func outboxBackoff(n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	if n > 12 {
+		return maxOutboxBackoff
+	}
+	return min(time.Duration(1<<n)*time.Second, maxOutboxBackoff)
+}
+
+// PurgeOutbox 清除投递时间早于 before 的消息，返回清除的数量
+func PurgeOutbox(ctx context.Context, db IDB, before time.Time) (int64, error) {
+	res, err := db.NewDelete().Model((*OutboxMessage)(nil)).
+		Where("delivered_at < ?", before).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
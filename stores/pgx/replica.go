@@ -0,0 +1,150 @@
+package pgx
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	replicaCheckInterval = 5 * time.Second
+	replicaPingTimeout   = 2 * time.Second
+)
+
+type replica struct {
+	db      *bun.DB
+	healthy atomic.Bool
+}
+
+// replicaSet 只读副本，轮询选取健康的副本
+type replicaSet struct {
+	mu    sync.RWMutex
+	items []*replica
+	next  atomic.Uint32
+	stop  chan struct{}
+}
+
+func (rs *replicaSet) pick() *bun.DB {
+	if rs == nil {
+		return nil
+	}
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	n := len(rs.items)
+	for range n {
+		r := rs.items[int(rs.next.Add(1)-1)%n]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return nil
+}
+
+func (rs *replicaSet) check(ctx context.Context) {
+	rs.mu.RLock()
+	items := rs.items
+	rs.mu.RUnlock()
+	for _, r := range items {
+		pctx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := r.db.PingContext(pctx)
+		cancel()
+		if ok := err == nil; r.healthy.Swap(ok) != ok {
+			logger().LogAttrs(ctx, slog.LevelInfo, "replica health changed",
+				slog.String("db", r.db.String()),
+				slog.Bool("healthy", ok),
+				slog.Any("err", err),
+			)
+		}
+	}
+}
+
+func (rs *replicaSet) loop() {
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.check(context.Background())
+		}
+	}
+}
+
+// AddReplicas 连接只读副本，ListModel、GetModel、Count、Exists 等读取轮询使用健康的副本，
+// 事务(RunInTx)及 ContextWithPrimary 的读取仍使用主库
+func (w *DB) AddReplicas(dsns ...string) error {
+	if len(dsns) == 0 {
+		return nil
+	}
+	var items []*replica
+	for _, dsn := range dsns {
+		db, _, err := openDB(dsn, w.replicaOptions())
+		if err != nil {
+			for _, r := range items {
+				_ = r.db.Close()
+			}
+			return err
+		}
+		r := &replica{db: db}
+		r.healthy.Store(true)
+		items = append(items, r)
+	}
+
+	if w.rs == nil {
+		w.rs = &replicaSet{stop: make(chan struct{})}
+		go w.rs.loop()
+	}
+	w.rs.mu.Lock()
+	w.rs.items = append(w.rs.items, items...)
+	w.rs.mu.Unlock()
+	return nil
+}
+
+// replicaOptions 副本使用与主库相同的连接选项
+func (w *DB) replicaOptions() *openOptions {
+	if w.opts == nil {
+		return newOpenOptions()
+	}
+	opt := *w.opts
+	opt.replicas = nil
+	return &opt
+}
+
+// Reader 返回用于只读查询的连接，没有可用的副本、在事务中或 ContextWithPrimary 时为主库
+func (w *DB) Reader(ctx context.Context) IDB {
+	if PrimaryFromContext(ctx) || InTxQueue(ctx) {
+		return w.DB
+	}
+	if db := w.rs.pick(); db != nil {
+		return db
+	}
+	return w.DB
+}
+
+// readerOf 参数为 *DB 时按 Reader 选取，否则(如 Tx)原样返回
+func readerOf(ctx context.Context, db IDB) IDB {
+	if w, ok := db.(*DB); ok {
+		return w.Reader(ctx)
+	}
+	return db
+}
+
+// Close 关闭主库及全部副本
+func (w *DB) Close() error {
+	if w.rs != nil {
+		close(w.rs.stop)
+		w.rs.mu.Lock()
+		for _, r := range w.rs.items {
+			_ = r.db.Close()
+		}
+		w.rs.items = nil
+		w.rs.mu.Unlock()
+		w.rs = nil
+	}
+	return w.DB.Close()
+}
@@ -0,0 +1,98 @@
+package pgx
+
+import (
+	"context"
+)
+
+// Repo 基于 DB 的类型化模型仓库，各方法与同名的自由函数行为一致
+//
+//	repo := NewRepo[Article](db)
+//	obj, err := repo.Get(ctx, id)
+type Repo[T any, P ModelPtr[T]] struct {
+	db *DB
+}
+
+// NewRepo 创建模型 T 的仓库
+func NewRepo[T any, P ModelPtr[T]](db *DB) *Repo[T, P] {
+	return &Repo[T, P]{db: db}
+}
+
+func (r *Repo[T, P]) DB() *DB {
+	return r.db
+}
+
+// New 返回一个空的模型对象
+func (r *Repo[T, P]) New() P {
+	return P(new(T))
+}
+
+// Get 按主键读取，可指定列
+func (r *Repo[T, P]) Get(ctx context.Context, id any, columns ...string) (P, error) {
+	obj := r.New()
+	if err := r.db.GetModel(ctx, obj, id, columns...); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// List 按 ListArg 查询列表，返回数据和总数
+func (r *Repo[T, P]) List(ctx context.Context, spec ListArg) (data []T, total int, err error) {
+	total, err = r.db.ListModel(ctx, spec, &data)
+	return
+}
+
+// Create 新建，参数同 DoInsert
+func (r *Repo[T, P]) Create(ctx context.Context, obj P, args ...any) error {
+	return DoInsert(ctx, r.db, obj, args...)
+}
+
+// CreateBatch 批量新建，参数同 DoInsertBatch
+func (r *Repo[T, P]) CreateBatch(ctx context.Context, objs []P, opts ...BatchOption) error {
+	models := make([]Model, len(objs))
+	for i, obj := range objs {
+		models[i] = obj
+	}
+	return DoInsertBatch(ctx, r.db, models, opts...)
+}
+
+// Update 更新变更的列或指定的列，参数同 DoUpdate
+func (r *Repo[T, P]) Update(ctx context.Context, obj P, columns ...string) error {
+	return DoUpdate(ctx, r.db, obj, columns...)
+}
+
+// Upsert 以 key 冲突时更新 columns，新对象 ID 为空时 key 才生效，否则以 id 判断冲突
+func (r *Repo[T, P]) Upsert(ctx context.Context, obj P, key string, columns ...string) error {
+	args := make([]any, 0, len(columns)+1)
+	args = append(args, key)
+	for _, c := range columns {
+		args = append(args, c)
+	}
+	return DoInsert(ctx, r.db, obj, args...)
+}
+
+// Delete 移动删除到 trash schema
+func (r *Repo[T, P]) Delete(ctx context.Context, id any) error {
+	obj := r.New()
+	if mi, ok := any(obj).(ModelIdentity); ok {
+		return r.db.DeleteModel(ctx, mi, id)
+	}
+	if !obj.SetID(id) || obj.IsZeroID() {
+		return ErrEmptyPK
+	}
+	return r.db.OpDeleteAny(ctx, getTableName(r.db, obj), obj.GetID())
+}
+
+// Undelete 从 trash schema 恢复
+func (r *Repo[T, P]) Undelete(ctx context.Context, id any) error {
+	return r.db.UndeleteModel(ctx, r.New(), id)
+}
+
+// Exists 参数同 Exists
+func (r *Repo[T, P]) Exists(ctx context.Context, args ...any) (bool, error) {
+	return Exists(ctx, r.db, r.New(), args...)
+}
+
+// Count 参数同 Count
+func (r *Repo[T, P]) Count(ctx context.Context, args ...any) int {
+	return Count(ctx, r.db, r.New(), args...)
+}
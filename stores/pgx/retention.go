@@ -0,0 +1,81 @@
+package pgx
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+const defaultPurgeBatch = 1000
+
+// PurgeResult 单个表的 trash 清除结果
+type PurgeResult struct {
+	Table  string `json:"table"`
+	Purged int    `json:"purged"` // 清除的数量
+}
+
+// DoPurgeTrash 分批清除 trash 表中删除时间早于 before 的记录
+//
+// 早于删除记录表的旧数据在记录表创建时(op_trash_log_ensure)补记为当时的时间。
+func DoPurgeTrash(ctx context.Context, db IDB, scCrap, table string, before time.Time, limit int) (res PurgeResult, err error) {
+	res.Table = table
+	if limit <= 0 {
+		limit = defaultPurgeBatch
+	}
+	for {
+		var n int
+		if err = db.NewRaw("SELECT op_trash_purge(?, ?, ?, ?)", scCrap, table, before, limit).Scan(ctx, &n); err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "purge trash fail",
+				slog.String("table", table),
+				slog.Int("purged", res.Purged),
+				slog.Any("err", err),
+			)
+			return
+		}
+		res.Purged += n
+		if n < limit {
+			break
+		}
+	}
+	logger().LogAttrs(ctx, slog.LevelInfo, "purge trash ok",
+		slog.String("table", table),
+		slog.Time("before", before),
+		slog.Int("purged", res.Purged),
+	)
+	return
+}
+
+// PurgeTrash 清除 table 在 trash schema 中删除超过 olderThan 的记录
+func (w *DB) PurgeTrash(ctx context.Context, table string, olderThan time.Duration) (PurgeResult, error) {
+	if len(table) == 0 || olderThan < 0 {
+		return PurgeResult{Table: table}, ErrInvalidArgs
+	}
+	_, scCrap := w.schemas(ctx)
+	return DoPurgeTrash(ctx, w.DB, scCrap, table, time.Now().Add(-olderThan), defaultPurgeBatch)
+}
+
+// PurgeExpiredTrash 按已注册模型的 TrashRetainer 策略清除过期的 trash 记录
+func (w *DB) PurgeExpiredTrash(ctx context.Context) ([]PurgeResult, error) {
+	var results []PurgeResult
+	for _, model := range allmodels {
+		// 注册的模型多为 nil 指针
+		tr, ok := newModelOf(model).(TrashRetainer)
+		if !ok || tr.TrashRetention() <= 0 {
+			continue
+		}
+		res, err := w.PurgeTrash(ctx, getTableName(w.DB, model), tr.TrashRetention())
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func newModelOf(model any) any {
+	if rt := reflect.TypeOf(model); rt != nil && rt.Kind() == reflect.Pointer {
+		return reflect.New(rt.Elem()).Interface()
+	}
+	return model
+}
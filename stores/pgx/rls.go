@@ -0,0 +1,165 @@
+package pgx
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/cupogo/andvari/models/field"
+)
+
+// 行级安全使用的会话变量，见 WithRLS
+const (
+	RLSTenant    = "app.tenant"     // 租户，见 ContextWithTenant
+	RLSActor     = "app.actor"      // 操作者，见 ContextWithActor
+	RLSOwnerID   = "app.owner_id"   // 所有者，见 Scope
+	RLSCreatorID = "app.creator_id" // 创建者，见 Scope
+	RLSBypass    = "app.bypass"     // 为 on 时不受 op_rls 策略限制，见 ContextWithoutScope
+)
+
+// WithRLS 开启后在 RunInTx 开始时以 SET LOCAL 设置 app.* 会话变量，
+// 经由 DB 的单条读写也放入事务中执行，以便使用 PostgreSQL 的行级安全策略
+func WithRLS() OpenOption {
+	return func(opt *openOptions) {
+		opt.rls = true
+	}
+}
+
+func (w *DB) rlsEnabled() bool {
+	return w.opts != nil && w.opts.rls
+}
+
+// scopeBypassed 是否以 ContextWithoutScope 解除了访问范围
+func scopeBypassed(ctx context.Context) bool {
+	s, ok := ctx.Value(scopeKey{}).(*Scope)
+	return ok && s == nil
+}
+
+// rlsSettings 返回 ctx 中的会话变量，依次为名称和值
+func rlsSettings(ctx context.Context) (kvs []string) {
+	if tenant := TenantFromContext(ctx); len(tenant) > 0 {
+		kvs = append(kvs, RLSTenant, tenant)
+	}
+	if actor := ActorFromContext(ctx); len(actor) > 0 {
+		kvs = append(kvs, RLSActor, actor)
+	}
+	if s, ok := ScopeFromContext(ctx); ok {
+		if s.OwnerID.Valid() {
+			kvs = append(kvs, RLSOwnerID, strconv.FormatInt(int64(s.OwnerID), 10))
+		}
+		if s.CreatorID.Valid() {
+			kvs = append(kvs, RLSCreatorID, strconv.FormatInt(int64(s.CreatorID), 10))
+		}
+	} else if scopeBypassed(ctx) {
+		kvs = append(kvs, RLSBypass, "on")
+	}
+	return
+}
+
+// setSessionVars 在事务中设置会话变量
+func (w *DB) setSessionVars(ctx context.Context, tx IDB) error {
+	if !w.rlsEnabled() {
+		return nil
+	}
+	kvs := rlsSettings(ctx)
+	if len(kvs) == 0 {
+		return nil
+	}
+	var (
+		sb   strings.Builder
+		args = make([]any, len(kvs))
+	)
+	sb.WriteString("SELECT ")
+	for i := 0; i < len(kvs); i += 2 {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("set_config(?, ?, true)")
+		args[i], args[i+1] = kvs[i], kvs[i+1]
+	}
+	_, err := tx.NewRaw(sb.String(), args...).Exec(ctx)
+	return err
+}
+
+// needSession 是否需要在事务开始时设置 search_path 或会话变量
+func (w *DB) needSession(ctx context.Context) bool {
+	if len(TenantFromContext(ctx)) > 0 {
+		return true
+	}
+	return w.rlsEnabled() && len(rlsSettings(ctx)) > 0
+}
+
+// setSession 在事务开始时设置租户的 search_path 及会话变量
+func (w *DB) setSession(ctx context.Context, tx IDB) error {
+	if tenant := TenantFromContext(ctx); len(tenant) > 0 {
+		if err := w.setSearchPath(ctx, tx, tenant); err != nil {
+			return err
+		}
+	}
+	return w.setSessionVars(ctx, tx)
+}
+
+// rlsColumns 返回模型行级安全策略的列，nullable 中的列允许为 0
+func rlsColumns(model any) (columns, nullable []string) {
+	m := scopeModel(model)
+	if mo, ok := m.(ModelOwner); ok {
+		columns = append(columns, field.OwnerID)
+		if mo.OwnerEmpty() {
+			nullable = append(nullable, field.OwnerID)
+		}
+	}
+	if _, ok := m.(ModelCreator); ok {
+		columns = append(columns, field.CreatorID)
+	}
+	return
+}
+
+// EnableRLS 为已注册且带有 OwnerField 或 CreatorField 的模型创建行级安全策略 op_rls，
+// models 为空时作用于全部符合的注册模型，force 时对表的所有者同样生效
+func (w *DB) EnableRLS(ctx context.Context, force bool, models ...any) error {
+	explicit := len(models) > 0
+	if !explicit {
+		models = allmodels
+	}
+	scDft, _ := w.schemas(ctx)
+	for _, model := range models {
+		if _, ok := registeredModel(model); !ok {
+			return ErrInvalidArgs
+		}
+		columns, nullable := rlsColumns(model)
+		if len(columns) == 0 {
+			if explicit {
+				return ErrInvalidArgs
+			}
+			continue
+		}
+		table := getTableName(w.DB, model)
+		_, err := w.NewRaw("SELECT op_rls_install(?, ?, ?, ?, ?)",
+			scDft, table, Array(columns), Array(nullable), force).Exec(ctx)
+		logger().LogAttrs(ctx, slog.LevelInfo, "install rls",
+			slog.String("table", table),
+			slog.Any("columns", columns),
+			slog.Any("err", err),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisableRLS 移除模型的行级安全策略，models 为空时作用于全部注册模型
+func (w *DB) DisableRLS(ctx context.Context, models ...any) error {
+	if len(models) == 0 {
+		models = allmodels
+	}
+	scDft, _ := w.schemas(ctx)
+	for _, model := range models {
+		table := getTableName(w.DB, model)
+		if _, err := w.NewRaw("SELECT op_rls_uninstall(?, ?)", scDft, table).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package pgx
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+
+	"github.com/cupogo/andvari/models/comm"
+	"github.com/cupogo/andvari/models/field"
+	"github.com/cupogo/andvari/models/oid"
+)
+
+type (
+	ModelOwner   = comm.ModelOwner
+	ModelCreator = comm.ModelCreator
+)
+
+// Scope 访问范围，放入 Context 后 QueryList、GetModel、DoUpdate、DoDeleteM
+// 只作用于所有者或创建者匹配的记录，新建时自动填充
+type Scope struct {
+	OwnerID   oid.OID // 所有者或组织，作用于 ModelOwner
+	CreatorID oid.OID // 创建者，作用于 ModelCreator
+}
+
+func (s Scope) IsZero() bool {
+	return !s.OwnerID.Valid() && !s.CreatorID.Valid()
+}
+
+type scopeKey struct{}
+
+// ContextWithScope 限定访问范围
+func ContextWithScope(ctx context.Context, s Scope) context.Context {
+	if s.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, scopeKey{}, &s)
+}
+
+// ContextWithoutScope 管理员等不受访问范围限制
+func ContextWithoutScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, (*Scope)(nil))
+}
+
+// ScopeFromContext 返回 Context 中的访问范围
+func ScopeFromContext(ctx context.Context) (Scope, bool) {
+	if s, ok := ctx.Value(scopeKey{}).(*Scope); ok && s != nil {
+		return *s, true
+	}
+	return Scope{}, false
+}
+
+// scopeModel 返回用于判断接口的模型实例，切片时为其元素
+func scopeModel(model any) any {
+	typ := reflect.TypeOf(model)
+	if typ == nil {
+		return nil
+	}
+	for typ.Kind() == reflect.Ptr {
+		if typ.Elem().Kind() != reflect.Slice {
+			break
+		}
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Ptr {
+		typ = reflect.PointerTo(typ)
+	}
+	return reflect.New(typ.Elem()).Interface()
+}
+
+// applyScope 为模型加上访问范围的条件，返回是否加了条件
+func applyScope(ctx context.Context, model any, qb QueryBuilder) bool {
+	s, ok := ScopeFromContext(ctx)
+	if !ok {
+		return false
+	}
+	m := scopeModel(model)
+	var applied bool
+	if mo, ok := m.(ModelOwner); ok && s.OwnerID.Valid() {
+		if mo.OwnerEmpty() {
+			qb.WhereGroup(" AND ", func(qb QueryBuilder) QueryBuilder {
+				return qb.Where("?TableAlias.? = ?", Ident(field.OwnerID), s.OwnerID).
+					WhereOr("?TableAlias.? = 0", Ident(field.OwnerID))
+			})
+		} else {
+			qb.Where("?TableAlias.? = ?", Ident(field.OwnerID), s.OwnerID)
+		}
+		applied = true
+	}
+	if _, ok := m.(ModelCreator); ok && s.CreatorID.Valid() {
+		qb.Where("?TableAlias.? = ?", Ident(field.CreatorID), s.CreatorID)
+		applied = true
+	}
+	return applied
+}
+
+// ApplyScope 为查询加上 Context 中访问范围的条件
+func ApplyScope(ctx context.Context, q *SelectQuery) *SelectQuery {
+	if m := q.GetModel(); m != nil {
+		applyScope(ctx, m.Value(), q.QueryBuilder())
+	}
+	return q
+}
+
+// applyScopeOnCreate 新建时填充空的所有者及创建者
+func applyScopeOnCreate(ctx context.Context, obj Model) {
+	s, ok := ScopeFromContext(ctx)
+	if !ok {
+		return
+	}
+	if mo, ok := obj.(ModelOwner); ok && s.OwnerID.Valid() && !mo.GetOwnerID().Valid() {
+		mo.SetOwnerID(s.OwnerID)
+	}
+	if mc, ok := obj.(ModelCreator); ok && s.CreatorID.Valid() && !mc.GetCreatorID().Valid() {
+		mc.SetCreatorID(s.CreatorID)
+	}
+}
+
+// checkScope 校验对象在访问范围内，不在时返回 ErrNotFound
+func checkScope(ctx context.Context, db IDB, obj Model) error {
+	if _, ok := ScopeFromContext(ctx); !ok {
+		return nil
+	}
+	q := db.NewSelect().Model(obj).WherePK()
+	if !applyScope(ctx, obj, q.QueryBuilder()) {
+		return nil
+	}
+	ok, err := q.Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		logger().LogAttrs(ctx, slog.LevelInfo, "out of scope",
+			slog.String("name", ModelName(obj)),
+			slog.Any("id", obj.GetID()),
+		)
+		return ErrNotFound
+	}
+	return nil
+}
+
+// inScope 对象是否在 ctx 的访问范围内
+func inScope(ctx context.Context, obj any) bool {
+	s, ok := ScopeFromContext(ctx)
+	if !ok {
+		return true
+	}
+	if mo, ok := obj.(ModelOwner); ok && s.OwnerID.Valid() {
+		if id := mo.GetOwnerID(); id != s.OwnerID && (!mo.OwnerEmpty() || !id.IsZero()) {
+			return false
+		}
+	}
+	if mc, ok := obj.(ModelCreator); ok && s.CreatorID.Valid() && mc.GetCreatorID() != s.CreatorID {
+		return false
+	}
+	return true
+}
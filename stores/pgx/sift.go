@@ -59,6 +59,10 @@ type ModelSpec struct {
 	Updated string `form:"updated" json:"updated,omitempty"  extensions:"x-order=4"`
 	// IsDelete 查询删除的记录
 	IsDelete bool `form:"isDelete" json:"isDelete,omitempty"  extensions:"x-order=5"`
+	// 删除时间，仅查询删除的记录时有效 形式： yyyy-mm-dd, 1_day, 2_weeks, 3_months
+	DeletedAt string `form:"deleted" json:"deleted,omitempty"  extensions:"x-order=6"`
+	// 删除者ID，仅查询删除的记录时有效
+	DeleterID string `form:"deleterID" json:"deleterID,omitempty"  extensions:"x-order=7"`
 
 	colinc []string
 	colexc []string
@@ -104,6 +108,14 @@ func (ms *ModelSpec) Deleted() bool {
 	return ms.IsDelete
 }
 
+// SiftTrash 按删除时间和删除者过滤删除的记录
+func (ms *ModelSpec) SiftTrash(q *SelectQuery) *SelectQuery {
+	q, _ = SiftDate(q, "deleted_at", ms.DeletedAt, false, false)
+	q, _ = SiftEqual(q, "deleter_id", ms.DeleterID, false)
+
+	return q
+}
+
 func (ms *ModelSpec) Sift(q *SelectQuery) *SelectQuery {
 	if len(ms.colexc) > 0 {
 		q.ExcludeColumn(ms.colexc...)
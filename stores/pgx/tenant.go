@@ -0,0 +1,197 @@
+package pgx
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cupogo/andvari/models/comm"
+)
+
+const defaultTenantPrefix = "tenant_"
+
+var reTenantID = regexp.MustCompile(`^[a-z0-9_]{1,48}$`)
+
+// TenantSchemaFunc 租户 id 对应的 schema 名称，trash schema 为其加上 _trash
+type TenantSchemaFunc func(tenant string) string
+
+// WithTenantSchema 自定义租户的 schema 名称，默认为 tenant_ 加租户 id
+func WithTenantSchema(fn TenantSchemaFunc) OpenOption {
+	return func(opt *openOptions) {
+		opt.tenantSchema = fn
+	}
+}
+
+// ContextWithTenant 指定租户，DB 的读写在该租户的 schema 中进行，
+// 租户 id 只能包含小写字母、数字及下划线
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantK, tenant)
+}
+
+func TenantFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(tenantK).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Tenant 已创建的租户，保存在默认 schema 中
+type Tenant struct {
+	comm.BaseModel `bun:"table:op_tenant,alias:ot" json:"-"`
+
+	ID      string    `bun:"id,pk,type:name" json:"id"`
+	Schema  string    `bun:"schema,notnull,type:name" json:"schema"`
+	Created time.Time `bun:"created,notnull,default:now()" json:"created"`
+}
+
+type Tenants []Tenant
+
+// tenantSchemas 返回租户的 schema 对
+func (w *DB) tenantSchemas(tenant string) (scDft, scCrap string) {
+	if w.opts != nil && w.opts.tenantSchema != nil {
+		scDft = w.opts.tenantSchema(tenant)
+	} else {
+		scDft = defaultTenantPrefix + tenant
+	}
+	return scDft, scDft + crapSuffix
+}
+
+// schemas 返回 ctx 中租户的 schema 对，没有租户时为 DB 的默认 schema
+func (w *DB) schemas(ctx context.Context) (scDft, scCrap string) {
+	if tenant := TenantFromContext(ctx); len(tenant) > 0 {
+		return w.tenantSchemas(tenant)
+	}
+	return w.scDft, w.scCrap
+}
+
+// setSearchPath 在事务中将 search_path 设为租户的 schema，默认 schema 及 public 在后，以便使用其中的函数
+func (w *DB) setSearchPath(ctx context.Context, tx IDB, tenant string) error {
+	if !reTenantID.MatchString(tenant) {
+		return ErrInvalidArgs
+	}
+	sc, _ := w.tenantSchemas(tenant)
+	path := quoteIdent(sc) + ", " + quoteIdent(w.scDft) + ", public"
+	_, err := tx.NewRaw("SELECT set_config('search_path', ?, true)", path).Exec(ctx)
+	return err
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// sessionTx db 为 *DB 且有租户或会话变量时，在设置了 search_path 及会话变量的事务中执行 fn，返回 false 表示无需处理
+func sessionTx(ctx context.Context, db IDB, fn func(ctx context.Context, tx IDB) error) (bool, error) {
+	w, ok := db.(*DB)
+	if !ok || !w.needSession(ctx) {
+		return false, nil
+	}
+	return true, w.RunInTx(ctx, nil, func(ctx context.Context, tx Tx) error {
+		return fn(ctx, tx)
+	})
+}
+
+// sessionRead 有租户或会话变量时在只读副本或主库的只读事务中执行 fn，已在事务中时也另开会话事务
+func (w *DB) sessionRead(ctx context.Context, fn func(ctx context.Context, db IDB) error) error {
+	if !w.needSession(ctx) {
+		return fn(ctx, w.Reader(ctx))
+	}
+	return w.Reader(ctx).RunInTx(ctx, &sql.TxOptions{ReadOnly: true}, func(ctx context.Context, tx Tx) error {
+		if err := w.setSession(ctx, tx); err != nil {
+			return err
+		}
+		return fn(ctx, tx)
+	})
+}
+
+// sessionReadOf db 为 *DB 时按 sessionRead 执行，否则(如 Tx)直接在 db 上执行
+func sessionReadOf(ctx context.Context, db IDB, fn func(ctx context.Context, db IDB) error) error {
+	if w, ok := db.(*DB); ok {
+		return w.sessionRead(ctx, fn)
+	}
+	return fn(ctx, db)
+}
+
+func (w *DB) ensureTenantTable(ctx context.Context) error {
+	_, err := w.NewCreateTable().Model((*Tenant)(nil)).
+		ModelTableExpr("?.op_tenant", Ident(w.scDft)).IfNotExists().Exec(ctx)
+	return err
+}
+
+// EnsureTenant 创建租户的 schema、全部注册的表及初始化 SQL，可重复执行
+func (w *DB) EnsureTenant(ctx context.Context, tenant string) error {
+	if !reTenantID.MatchString(tenant) {
+		return ErrInvalidArgs
+	}
+	if err := w.ensureTenantTable(ctx); err != nil {
+		return err
+	}
+	sc, crap := w.tenantSchemas(tenant)
+	if err := EnsureSchema(ctx, w.DB, quoteIdent(sc)); err != nil {
+		return err
+	}
+	ctx = ContextWithTenant(ctx, tenant)
+	err := w.RunInTx(ctx, nil, func(ctx context.Context, tx Tx) error {
+		if err := CreateModels(ctx, tx, false, allmodels...); err != nil {
+			return err
+		}
+		for _, dbfs := range alldbfs {
+			if _, err := BulkFsSQLs(ctx, tx, dbfs); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.NewRaw("SELECT op_trash_log_ensure(?)", crap).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewInsert().Model(&Tenant{ID: tenant, Schema: sc}).
+			ModelTableExpr("?.op_tenant", Ident(w.scDft)).ExcludeColumn("created").
+			On("CONFLICT (id) DO NOTHING").Exec(ctx)
+		return err
+	})
+	logger().LogAttrs(ctx, slog.LevelInfo, "ensure tenant",
+		slog.String("tenant", tenant),
+		slog.String("schema", sc),
+		slog.Any("err", err),
+	)
+	return err
+}
+
+// ListTenants 返回已创建的租户
+func (w *DB) ListTenants(ctx context.Context) (data Tenants, err error) {
+	if err = w.ensureTenantTable(ctx); err != nil {
+		return
+	}
+	err = w.NewSelect().Model(&data).
+		ModelTableExpr("?.op_tenant AS ot", Ident(w.scDft)).OrderExpr("id").Scan(ctx)
+	return
+}
+
+// ForEachTenant 依次对每个租户执行 fn，ctx 中带有该租户，遇到错误时停止
+func (w *DB) ForEachTenant(ctx context.Context, fn func(ctx context.Context, tenant Tenant) error) error {
+	tenants, err := w.ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tenants {
+		if err := fn(ContextWithTenant(ctx, t.ID), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AlterTenants 对全部租户执行 AlterModels
+func (w *DB) AlterTenants(ctx context.Context, opts ...AlterOption) error {
+	return w.ForEachTenant(ctx, func(ctx context.Context, t Tenant) error {
+		return w.AlterModels(ctx, opts...)
+	})
+}
+
+// SyncTenants 对全部租户执行 SyncSchema
+func (w *DB) SyncTenants(ctx context.Context, opts ...AlterOption) error {
+	return w.ForEachTenant(ctx, func(ctx context.Context, t Tenant) error {
+		return w.SyncSchema(ctx, opts...)
+	})
+}
@@ -6,14 +6,27 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/uptrace/bun"
+
 	"github.com/cupogo/andvari/models/field"
 	"github.com/cupogo/andvari/utils/sqlutil"
 )
 
 const (
 	textVec = "ts_vec"
+
+	TsRankColumn     = "ts_rank"     // 相关度的结果列，见 SetTsRank
+	TsHeadlineColumn = "ts_headline" // 高亮片段的结果列，见 SetTsHeadline
 )
 
+// TextSearchResult 嵌入到模型中以接收相关度及高亮片段，ListModel 的结果中带有这两个字段
+type TextSearchResult struct {
+	// 相关度
+	TsRank float32 `bun:"ts_rank,scanonly" json:"tsRank,omitempty"`
+	// 高亮片段，键为列名
+	TsHeadline map[string]string `bun:"ts_headline,scanonly,type:jsonb" json:"tsHeadline,omitempty"`
+}
+
 type TextSearchSpec struct {
 	cfgname string
 	enabled bool
@@ -21,6 +34,10 @@ type TextSearchSpec struct {
 	fallbacks []string // columns
 	bothmatch bool     // both left and right match '%abc%'
 
+	rankfn    string   // ts_rank or ts_rank_cd
+	headlines []string // columns for ts_headline
+	hlopts    string   // ts_headline options
+
 	// 关键词搜索
 	SearchKeyWord string `json:"skw,omitempty" form:"skw" extensions:"x-order=8"`
 	// 匹配风格 `web` `plain` `valid` 或空
@@ -44,6 +61,26 @@ func (tss *TextSearchSpec) SetBothMatch(yn bool) {
 	tss.bothmatch = yn
 }
 
+// SetTsRank 按相关度降序排列(其后再按 ApplyQuerySort 的字段)，并返回 ts_rank 列，
+// cd 时使用 ts_rank_cd。相关度无法用于游标分页，QueryPager 首页改用 offset 分页，带游标时返回 ErrInvalidArgs
+func (tss *TextSearchSpec) SetTsRank(cd bool) {
+	if cd {
+		tss.rankfn = "ts_rank_cd"
+	} else {
+		tss.rankfn = "ts_rank"
+	}
+}
+
+// SetTsHeadline 为指定的列返回 ts_headline 高亮片段，opts 如 "MaxFragments=2, StartSel=<b>, StopSel=</b>"
+func (tss *TextSearchSpec) SetTsHeadline(opts string, cols ...string) {
+	tss.hlopts, tss.headlines = opts, cols
+}
+
+// tsRanked 是否按相关度排序
+func (tss *TextSearchSpec) tsRanked() bool {
+	return tss.enabled && len(tss.rankfn) > 0 && len(tss.SearchKeyWord) > 0
+}
+
 func (tss *TextSearchSpec) TsEnabled() bool {
 	return tss.enabled
 }
@@ -62,6 +99,9 @@ func (tss *TextSearchSpec) SiftTS(q *SelectQuery, hide bool) *SelectQuery {
 	if len(tss.SearchKeyWord) == 0 {
 		return q
 	}
+	if tss.enabled {
+		q = tss.applyRank(q)
+	}
 	return q.WhereGroup(" AND ", func(sq *SelectQuery) *SelectQuery {
 		if len(tss.fallbacks) > 0 && len(tss.fallbacks[0]) > 0 {
 			for _, col := range tss.fallbacks {
@@ -76,6 +116,49 @@ func (tss *TextSearchSpec) SiftTS(q *SelectQuery, hide bool) *SelectQuery {
 	})
 }
 
+// applyRank 加上相关度排序、ts_rank 及 ts_headline 列，模型中没有对应字段(见 TextSearchResult)时不返回该列
+func (tss *TextSearchSpec) applyRank(q *SelectQuery) *SelectQuery {
+	if len(tss.rankfn) == 0 && len(tss.headlines) == 0 {
+		return q
+	}
+	tsq := Safe(getTsQuery(tss.cfgname, tss.SearchStyle, strings.ToLower(tss.SearchKeyWord)))
+	hasField := func(name string) bool {
+		if tm, ok := q.GetModel().(bun.TableModel); ok {
+			_, ok = tm.Table().FieldMap[name]
+			return ok
+		}
+		return false
+	}
+	// 未指定列时先列出模型的全部列，否则只会查询附加的列
+	withColumns := func() { q.ExcludeColumn() }
+	if len(tss.rankfn) > 0 {
+		if hasField(TsRankColumn) {
+			withColumns()
+			q.ColumnExpr("?(?, ?) AS ?", Safe(tss.rankfn), Ident(textVec), tsq, Ident(TsRankColumn))
+		}
+		q.OrderExpr("?(?, ?) DESC", Safe(tss.rankfn), Ident(textVec), tsq)
+	}
+	if len(tss.headlines) > 0 && hasField(TsHeadlineColumn) {
+		withColumns()
+		var (
+			sb   strings.Builder
+			args []any
+		)
+		sb.WriteString("jsonb_build_object(")
+		for i, col := range tss.headlines {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("?, ts_headline(?, ?TableAlias.?::text, ?, ?)")
+			args = append(args, col, tss.cfgname, Ident(col), tsq, tss.hlopts)
+		}
+		sb.WriteString(") AS ?")
+		args = append(args, Ident(TsHeadlineColumn))
+		q.ColumnExpr(sb.String(), args...)
+	}
+	return q
+}
+
 // Deprecated: use TextSearchSpec.Sift
 func DoApplyTsQuery(enabled bool, cfgname string, q *SelectQuery, kw, sty string, cols ...string) *SelectQuery {
 	tss := TextSearchSpec{
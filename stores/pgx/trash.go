@@ -132,6 +132,12 @@ func syncTrashTables(ctx context.Context, db IDB, defSchema, trashSchema string,
 			if err != nil {
 				return
 			}
+			if option.index {
+				err = syncTrashIndexes(ctx, db, defSchema, trashSchema, def.TableName, option)
+				if err != nil {
+					return
+				}
+			}
 		}
 	}
 
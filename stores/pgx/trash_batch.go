@@ -0,0 +1,136 @@
+package pgx
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+)
+
+// AffectResult 批量移动删除/恢复中单个 id 的结果
+//
+// Ret: 1 成功, -1 记录不存在(恢复时也可能是记录已存在), -2 表不存在, -3 trash 表不存在
+type AffectResult struct {
+	ID  any `bun:"id" json:"id"`
+	Ret int `bun:"ret" json:"ret"`
+}
+
+func (r AffectResult) OK() bool {
+	return r.Ret == 1
+}
+
+type AffectResults []AffectResult
+
+// Affected 返回成功的数量
+func (z AffectResults) Affected() (n int) {
+	for _, r := range z {
+		if r.OK() {
+			n++
+		}
+	}
+	return
+}
+
+// idsArray 按元素类型返回主键数组的类型，字串为 text[]，整数为 bigint[]
+func idsArray(ids any) (typ string, n int, err error) {
+	rv := reflect.ValueOf(ids)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", 0, ErrInvalidArgs
+	}
+	switch rv.Type().Elem().Kind() {
+	case reflect.String:
+		typ = "text[]"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		typ = "bigint[]"
+	default:
+		return "", 0, ErrInvalidArgs
+	}
+	return typ, rv.Len(), nil
+}
+
+func doAffectBatch(ctx context.Context, db IDB, fn, scDft, scCrap, table string, ids any) (AffectResults, error) {
+	typ, n, err := idsArray(ids)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	var res AffectResults
+	err = runWithDeleteMeta(ctx, db, func(ctx context.Context, db IDB) error {
+		return db.NewRaw("SELECT id, ret FROM ?(?, ?, ?, ?::?)", Safe(fn), scDft, scCrap, table, Array(ids), Safe(typ)).
+			Scan(ctx, &res)
+	})
+	logBatchAffect(ctx, fn, table, n, res, err)
+	if err == nil {
+		invalidateCachedAffected(ctx, db, table, res)
+	}
+	return res, err
+}
+
+func logBatchAffect(ctx context.Context, fn, table string, n int, res AffectResults, err error) {
+	if err != nil {
+		logger().LogAttrs(ctx, slog.LevelInfo, "batch affect fail",
+			slog.String("fn", fn),
+			slog.String("table", table),
+			slog.Int("count", n),
+			slog.Any("err", err),
+		)
+	} else {
+		logger().LogAttrs(ctx, slog.LevelInfo, "batch affect ok",
+			slog.String("fn", fn),
+			slog.String("table", table),
+			slog.Int("count", len(res)),
+			slog.Int("affected", res.Affected()),
+		)
+	}
+}
+
+// DoDeleteBatchT 批量移动删除到 trash schema，ids 为整数或字串切片，一条语句完成
+func DoDeleteBatchT(ctx context.Context, db IDB, scDft, scCrap string, table string, ids any) (AffectResults, error) {
+	return doAffectBatch(ctx, db, "op_affect_delete_batch", scDft, scCrap, table, ids)
+}
+
+// DoUndeleteBatchT 批量从 trash schema 恢复
+func DoUndeleteBatchT(ctx context.Context, db IDB, scDft, scCrap string, table string, ids any) (AffectResults, error) {
+	return doAffectBatch(ctx, db, "op_affect_undelete_batch", scDft, scCrap, table, ids)
+}
+
+// DoDeleteBatchQ 移动删除 q 所匹配的全部记录，q 需基于模型并包含 id 列
+//
+//	q := db.NewSelect().Model((*Article)(nil)).Where("tenant_id = ?", tid)
+//	res, err := DoDeleteBatchQ(ctx, db, scDft, scCrap, q)
+func DoDeleteBatchQ(ctx context.Context, db IDB, scDft, scCrap string, q *SelectQuery) (AffectResults, error) {
+	table := q.GetTableName()
+	if len(table) == 0 {
+		return nil, ErrInvalidArgs
+	}
+	var res AffectResults
+	err := runWithDeleteMeta(ctx, db, func(ctx context.Context, db IDB) error {
+		return db.NewRaw("SELECT id, ret FROM op_affect_delete_batch(?, ?, ?, ARRAY(SELECT s.id FROM (?) AS s))",
+			scDft, scCrap, table, q).Scan(ctx, &res)
+	})
+	if err == ErrNoRows {
+		err = nil
+	}
+	logBatchAffect(ctx, "op_affect_delete_batch", table, len(res), res, err)
+	if err == nil {
+		invalidateCachedAffected(ctx, db, table, res)
+	}
+	return res, err
+}
+
+// DeleteBatch 批量移动删除到 trash schema
+func (w *DB) DeleteBatch(ctx context.Context, table string, ids any) (AffectResults, error) {
+	scDft, scCrap := w.schemas(ctx)
+	return DoDeleteBatchT(ContextWithDB(ctx, w), w.DB, scDft, scCrap, table, ids)
+}
+
+// UndeleteBatch 批量从 trash schema 恢复
+func (w *DB) UndeleteBatch(ctx context.Context, table string, ids any) (AffectResults, error) {
+	scDft, scCrap := w.schemas(ctx)
+	return DoUndeleteBatchT(ContextWithDB(ctx, w), w.DB, scDft, scCrap, table, ids)
+}
+
+// DeleteWhere 移动删除 q 所匹配的全部记录
+func (w *DB) DeleteWhere(ctx context.Context, q *SelectQuery) (AffectResults, error) {
+	scDft, scCrap := w.schemas(ctx)
+	return DoDeleteBatchQ(ContextWithDB(ctx, w), w.DB, scDft, scCrap, q)
+}
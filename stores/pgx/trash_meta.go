@@ -0,0 +1,79 @@
+package pgx
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/uptrace/bun/schema"
+)
+
+// TrashSifter 查询删除的记录时按删除信息过滤，ModelSpec 已实现
+type TrashSifter interface {
+	SiftTrash(q *SelectQuery) *SelectQuery
+}
+
+var trashMetaColumns = []string{"deleted_at", "deleter_id", "delete_reason"}
+
+// runWithDeleteMeta 将 Context 中的删除者及原因设为事务内变量，供 op_trash_log_mark 记录
+func runWithDeleteMeta(ctx context.Context, db IDB, fn func(ctx context.Context, db IDB) error) error {
+	deleter, reason := DeleterFromContext(ctx), DeleteReasonFromContext(ctx)
+	if len(deleter) == 0 && len(reason) == 0 {
+		return fn(ctx, db)
+	}
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx Tx) error {
+		_, err := tx.NewRaw("SELECT set_config('andvari.deleter_id', ?, true), set_config('andvari.delete_reason', ?, true)",
+			deleter, reason).Exec(ctx)
+		if err != nil {
+			logger().LogAttrs(ctx, slog.LevelInfo, "set delete meta fail",
+				slog.String("deleter", deleter),
+				slog.Any("err", err),
+			)
+			return err
+		}
+		return fn(ctx, tx)
+	})
+}
+
+// ensureTrashLog 确保 scCrap 中存在删除记录表，成功后不再重复检查
+func (w *DB) ensureTrashLog(ctx context.Context, scCrap string) error {
+	if _, ok := w.trashLogs.Load(scCrap); ok {
+		return nil
+	}
+	if _, err := w.DB.NewRaw("SELECT op_trash_log_ensure(?)", scCrap).Exec(ctx); err != nil {
+		logger().LogAttrs(ctx, slog.LevelInfo, "ensure trash log fail",
+			slog.String("schema", scCrap),
+			slog.Any("err", err),
+		)
+		return err
+	}
+	w.trashLogs.Store(scCrap, struct{}{})
+	return nil
+}
+
+// applyTrashTable 将查询指向 trash schema，并关联删除记录以读取或过滤删除信息
+func applyTrashTable(ctx context.Context, q *SelectQuery, scCrap string, spec ListArg) *SelectQuery {
+	tm, ok := q.GetModel().(interface{ Table() *schema.Table })
+	if !ok {
+		return q.ModelTableExpr(scCrap + ".?TableName AS ?TableAlias")
+	}
+	tb := tm.Table()
+	q.ModelTableExpr("(SELECT t.*, l.deleted_at, l.deleter_id, l.reason AS delete_reason FROM ?.?TableName AS t"+
+		" LEFT JOIN ?.op_trash_log AS l ON l.table_name = ? AND l.id = t.id::text) AS ?TableAlias",
+		Ident(scCrap), Ident(scCrap), tb.Name)
+
+	// 模型嵌入了 comm.TrashMeta 时读取删除信息
+	if _, ok := tb.FieldMap[trashMetaColumns[0]]; ok {
+		if !spec.HasColumn() && !spec.HasExcludeColumn() &&
+			len(ColumnsFromContext(ctx)) == 0 && len(ExcludesFromContext(ctx)) == 0 {
+			for _, f := range tb.Fields {
+				q.Column(f.Name)
+			}
+		}
+		q.Column(trashMetaColumns...)
+	}
+
+	if ts, ok := spec.(TrashSifter); ok {
+		q = ts.SiftTrash(q)
+	}
+	return q
+}
@@ -0,0 +1,103 @@
+package pgx
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+type txQueueKey struct{}
+
+// txQueue 事务提交后执行的任务，回滚时丢弃
+type txQueue struct {
+	mu   sync.Mutex
+	db   IDB
+	fns  []func(ctx context.Context, db IDB)
+	done bool
+}
+
+func txQueueFromContext(ctx context.Context) *txQueue {
+	if q, ok := ctx.Value(txQueueKey{}).(*txQueue); ok {
+		return q
+	}
+	return nil
+}
+
+// add 加入队列，没有进行中的事务时返回 false
+func (q *txQueue) add(fn func(ctx context.Context, db IDB)) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.done {
+		return false
+	}
+	q.fns = append(q.fns, fn)
+	return true
+}
+
+// push 加入队列，没有进行中的事务时立即执行
+func (q *txQueue) push(ctx context.Context, db IDB, fn func(ctx context.Context, db IDB)) {
+	if !q.add(fn) {
+		fn(ctx, db)
+	}
+}
+
+func (q *txQueue) active() bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return !q.done
+}
+
+func (q *txQueue) finish(ctx context.Context, committed bool) {
+	q.mu.Lock()
+	fns := q.fns
+	q.fns, q.done = nil, true
+	q.mu.Unlock()
+	if !committed {
+		return
+	}
+	for _, fn := range fns {
+		fn(ctx, q.db)
+	}
+}
+
+// RunInTx 同 bun.DB.RunInTx，ctx 携带当前 DB(见 ContextWithDB)，有租户时设置 search_path，
+// 开启 WithRLS 时设置 app.* 会话变量，
+// 另外在提交后执行事务中登记的任务(如 after-commit 事件)，回滚时丢弃。
+//
+// 每次调用都开启独立的事务并使用自己的任务队列，即使已在另一个事务中；
+// 在外层 Tx 上嵌套(tx.RunInTx)时沿用外层的队列，随外层提交。
+func (w *DB) RunInTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx Tx) error) error {
+	ctx = ContextWithDB(ctx, w)
+	if w.needSession(ctx) {
+		inner := fn
+		fn = func(ctx context.Context, tx Tx) error {
+			if err := w.setSession(ctx, tx); err != nil {
+				return err
+			}
+			return inner(ctx, tx)
+		}
+	}
+	q := &txQueue{db: w}
+	ctx = context.WithValue(ctx, txQueueKey{}, q)
+	err := w.DB.RunInTx(ctx, opts, fn)
+	q.finish(ctx, err == nil)
+	return err
+}
+
+// AfterCommit 登记在 DB.RunInTx 提交后执行的任务，回滚时丢弃，不在事务中时立即执行
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	txQueueFromContext(ctx).push(ctx, nil, func(ctx context.Context, _ IDB) {
+		fn(ctx)
+	})
+}
+
+// InTxQueue 当前 ctx 是否处于 DB.RunInTx 的事务中
+func InTxQueue(ctx context.Context) bool {
+	return txQueueFromContext(ctx).active()
+}
@@ -0,0 +1,14 @@
+package pgx
+
+import (
+	"database/sql"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// offlineDB builds queries without a connection
+func offlineDB() *bun.DB {
+	return bun.NewDB(sql.OpenDB(pgdriver.NewConnector()), pgdialect.New())
+}
@@ -0,0 +1,67 @@
+package pgx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cupogo/andvari/models/comm"
+	"github.com/cupogo/andvari/models/oid"
+)
+
+type cursorSpec struct {
+	comm.CursorPageSpec
+	ModelSpec
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := oid.NewID(oid.OtArticle)
+	c := pageCursor{Keys: []string{"created", "id"}, Values: []any{"2026-01-02T03:04:05Z", int64(id)}, Backward: true}
+	s := c.Encode()
+	assert.NotEmpty(t, s)
+
+	got, err := decodeCursor(s)
+	assert.NoError(t, err)
+	assert.True(t, got.Backward)
+	assert.Equal(t, c.Keys, got.Keys)
+	n, ok := got.Values[1].(json.Number)
+	assert.True(t, ok)
+	assert.Equal(t, int64(id), mustInt64(t, n))
+
+	_, err = decodeCursor("!!")
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+}
+
+func mustInt64(t *testing.T, n json.Number) int64 {
+	v, err := n.Int64()
+	assert.NoError(t, err)
+	return v
+}
+
+func TestCursorSortRules(t *testing.T) {
+	spec := &cursorSpec{}
+	spec.Sort = "-created"
+	rules := cursorSortRules(spec)
+	assert.Equal(t, []string{"created", "id"}, sortKeys(rules))
+	assert.True(t, rules[1].desc())
+
+	spec.Sort = "id ASC"
+	assert.Equal(t, []string{"id"}, sortKeys(cursorSortRules(spec)))
+
+	spec.Sort = "unknown"
+	assert.Equal(t, []string{"id"}, sortKeys(cursorSortRules(spec)))
+}
+
+func TestApplyKeyset(t *testing.T) {
+	rules := []sortRule{{key: "created", op: "DESC"}, {key: "id", op: "DESC"}}
+	cur := &pageCursor{Keys: sortKeys(rules), Values: []any{"2026-01-02", 9}}
+	q := offlineDB().NewSelect().Model(&Clauses{})
+	sql := applyKeyset(q, rules, cur).String()
+	assert.Contains(t, sql, `(("c"."created" < '2026-01-02')) OR (("c"."created" = '2026-01-02') AND ("c"."id" < 9))`)
+
+	cur.Backward = true
+	q = offlineDB().NewSelect().Model(&Clauses{})
+	sql = applyKeyset(q, rules, cur).String()
+	assert.Contains(t, sql, `("c"."id" > 9)`)
+}
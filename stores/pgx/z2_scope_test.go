@@ -0,0 +1,48 @@
+package pgx
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cupogo/andvari/models/comm"
+	"github.com/cupogo/andvari/models/oid"
+)
+
+type ownedModel struct {
+	comm.BaseModel `bun:"table:t_owned,alias:o"`
+
+	comm.DefaultModel
+	comm.OwnerField
+}
+
+func TestApplyScope(t *testing.T) {
+	db := offlineDB()
+	owner, creator := oid.NewID(oid.OtAccount), oid.NewID(oid.OtAccount)
+	ctx := ContextWithScope(context.Background(), Scope{OwnerID: owner, CreatorID: creator})
+
+	var data []ownedModel
+	q := QueryList(ctx, db, nil, &data)
+	sql := q.String()
+	assert.Contains(t, sql, `"o"."owner_id" = `+strconv.FormatInt(int64(owner), 10))
+	assert.Contains(t, sql, `"o"."creator_id" = `+strconv.FormatInt(int64(creator), 10))
+
+	q = QueryList(ContextWithoutScope(ctx), db, nil, &data)
+	assert.NotContains(t, q.String(), "owner_id =")
+
+	q = ApplyScope(ctx, db.NewSelect().Model((*Clause)(nil)))
+	assert.NotContains(t, q.String(), "owner_id =")
+	assert.Contains(t, q.String(), `"c"."creator_id" = `)
+
+	obj := new(ownedModel)
+	applyScopeOnCreate(ctx, obj)
+	assert.Equal(t, owner, obj.OwnerID)
+	assert.Equal(t, creator, obj.CreatorID)
+
+	other := oid.NewID(oid.OtAccount)
+	obj = &ownedModel{OwnerField: comm.OwnerField{OwnerID: other}}
+	applyScopeOnCreate(ctx, obj)
+	assert.Equal(t, other, obj.OwnerID)
+}
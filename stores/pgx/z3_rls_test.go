@@ -0,0 +1,39 @@
+package pgx
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cupogo/andvari/models/field"
+	"github.com/cupogo/andvari/models/oid"
+)
+
+func TestRLSSettings(t *testing.T) {
+	owner := oid.NewID(oid.OtAccount)
+	ctx := ContextWithTenant(context.Background(), "acme")
+	ctx = ContextWithActor(ctx, "alice")
+	ctx = ContextWithScope(ctx, Scope{OwnerID: owner})
+	assert.Equal(t, []string{
+		RLSTenant, "acme",
+		RLSActor, "alice",
+		RLSOwnerID, strconv.FormatInt(int64(owner), 10),
+	}, rlsSettings(ctx))
+
+	assert.Empty(t, rlsSettings(context.Background()))
+	assert.Equal(t, []string{RLSBypass, "on"}, rlsSettings(ContextWithoutScope(context.Background())))
+
+	w := &DB{DB: offlineDB(), opts: newOpenOptions()}
+	assert.False(t, w.needSession(ContextWithActor(context.Background(), "alice")))
+	w.opts = newOpenOptions(WithRLS())
+	assert.True(t, w.needSession(ContextWithActor(context.Background(), "alice")))
+	assert.False(t, w.needSession(context.Background()))
+
+	columns, nullable := rlsColumns((*ownedModel)(nil))
+	assert.Equal(t, []string{field.OwnerID, field.CreatorID}, columns)
+	assert.Empty(t, nullable)
+	columns, _ = rlsColumns((*Clause)(nil))
+	assert.Equal(t, []string{field.CreatorID}, columns)
+}
@@ -0,0 +1,103 @@
+package pgx
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cupogo/andvari/models/comm"
+	"github.com/cupogo/andvari/models/oid"
+)
+
+type cachedModel struct {
+	comm.BaseModel `bun:"table:t_cached,alias:cm"`
+
+	comm.DefaultModel
+	comm.OwnerField
+
+	Name string            `bun:"name"`
+	Meta map[string]string `bun:"meta,type:jsonb"`
+	Tags []string          `bun:"tags,array"`
+}
+
+func (*cachedModel) CacheTTL() time.Duration { return time.Minute }
+
+func TestModelCache(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRUCache(2)
+	lru.Set(ctx, "a", 1, time.Minute)
+	lru.Set(ctx, "b", 2, time.Minute)
+	_, ok := lru.Get(ctx, "a")
+	assert.True(t, ok)
+	lru.Set(ctx, "c", 3, time.Minute) // 淘汰最久未用的 b
+	_, ok = lru.Get(ctx, "b")
+	assert.False(t, ok)
+	lru.Set(ctx, "d", 4, -time.Second)
+	_, ok = lru.Get(ctx, "d")
+	assert.False(t, ok)
+	lru.Delete(ctx, "a")
+	_, ok = lru.Get(ctx, "a")
+	assert.False(t, ok)
+
+	w := &DB{DB: offlineDB(), opts: newOpenOptions(WithModelCache(NewLRUCache(8)))}
+	owner := oid.NewID(oid.OtAccount)
+	obj := &cachedModel{Name: "hot"}
+	obj.SetID(oid.NewID(oid.OtDefault))
+	obj.OwnerID = owner
+
+	c, key, ttl := modelCacheFor(ctx, w, obj, nil)
+	assert.NotNil(t, c)
+	assert.Equal(t, time.Minute, ttl)
+	assert.NotEqual(t, key, cacheKey(ContextWithTenant(ctx, "acme"), obj))
+	storeCached(ctx, c, key, obj, ttl)
+	obj.Name = "changed"
+
+	got := new(cachedModel)
+	assert.True(t, loadCached(ctx, c, key, got))
+	assert.Equal(t, "hot", got.Name)
+	assert.Equal(t, obj.ID, got.ID)
+	assert.False(t, loadCached(ctx, c, key, new(ownedModel)))
+	assert.True(t, loadCached(ContextWithScope(ctx, Scope{OwnerID: owner}), c, key, got))
+	assert.False(t, loadCached(ContextWithScope(ctx, Scope{OwnerID: oid.NewID(oid.OtAccount)}), c, key, got))
+
+	// 读出的是独立的拷贝，修改 map、切片不影响缓存
+	obj.Meta, obj.Tags = map[string]string{"k": "v"}, []string{"a"}
+	obj.SetChange("name")
+	storeCached(ctx, c, key, obj, ttl)
+	obj.Meta["k"] = "changed"
+	assert.True(t, loadCached(ctx, c, key, got))
+	assert.Zero(t, got.CountChange())
+	got.Meta["k"], got.Meta["x"] = "dirty", "y"
+	got.Tags[0] = "dirty"
+	got = new(cachedModel)
+	assert.True(t, loadCached(ctx, c, key, got))
+	assert.Equal(t, map[string]string{"k": "v"}, got.Meta)
+	assert.Equal(t, []string{"a"}, got.Tags)
+
+	c, _, _ = modelCacheFor(ctx, w, obj, []string{"name"})
+	assert.Nil(t, c)
+	c, _, _ = modelCacheFor(ContextWithPrimary(ctx), w, obj, nil)
+	assert.Nil(t, c)
+	c, _, _ = modelCacheFor(ctx, w, new(ownedModel), nil)
+	assert.Nil(t, c)
+	c, _, _ = modelCacheFor(ctx, &DB{DB: offlineDB(), opts: newOpenOptions()}, obj, nil)
+	assert.Nil(t, c)
+
+	invalidateCached(ctx, w, obj)
+	assert.False(t, loadCached(ctx, w.opts.cache, key, got))
+
+	// 批量移动时按表名找到注册的模型
+	saved := allmodels
+	defer func() { allmodels = saved }()
+	allmodels = append(slices.Clip(allmodels), (*cachedModel)(nil))
+	table := getTableName(w, obj)
+	storeCached(ctx, w.opts.cache, key, obj, ttl)
+	invalidateCachedAffected(ctx, w, table, AffectResults{{ID: int64(obj.ID), Ret: -1}})
+	assert.True(t, loadCached(ctx, w.opts.cache, key, got))
+	invalidateCachedAffected(ctx, w, table, AffectResults{{ID: int64(obj.ID), Ret: 1}})
+	assert.False(t, loadCached(ctx, w.opts.cache, key, got))
+	assert.Nil(t, cachedModelOf(w, "t_none"))
+}
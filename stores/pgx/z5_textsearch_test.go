@@ -0,0 +1,67 @@
+package pgx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cupogo/andvari/models/comm"
+)
+
+type rankedCursorSpec struct {
+	comm.CursorPageSpec
+	ModelSpec
+	TextSearchSpec
+}
+
+func TestTextSearchRankCursor(t *testing.T) {
+	spec := &rankedCursorSpec{}
+	spec.SearchKeyWord = "Hello"
+	spec.SetTsConfig("mycfg", true)
+	assert.False(t, spec.tsRanked())
+	spec.SetTsRank(false)
+	assert.True(t, spec.tsRanked())
+
+	// 相关度排序与游标分页不能同时使用
+	spec.Cursor = (&pageCursor{Keys: []string{"id"}, Values: []any{1}}).Encode()
+	var data []searchModel
+	q := spec.TextSearchSpec.Sift(offlineDB().NewSelect().Model(&data))
+	_, err := QueryPager(context.Background(), spec, q)
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+}
+
+type searchModel struct {
+	comm.BaseModel `bun:"table:t_search,alias:s"`
+
+	comm.DefaultModel
+	Title string `bun:"title"`
+	TextSearchResult
+}
+
+func TestTextSearchRank(t *testing.T) {
+	tss := &TextSearchSpec{SearchKeyWord: "Hello"}
+	tss.SetTsConfig("mycfg", true)
+	tss.SetTsRank(false)
+	tss.SetTsHeadline("MaxFragments=1", "title")
+
+	var data []searchModel
+	q := tss.SiftTS(offlineDB().NewSelect().Model(&data), false)
+	q.OrderExpr("id DESC")
+	sql := q.String()
+	tsq := `phraseto_tsquery('mycfg', 'hello')`
+	assert.Contains(t, sql, `"s"."title", ts_rank("ts_vec", `+tsq+`) AS "ts_rank"`)
+	assert.Contains(t, sql, `jsonb_build_object('title', ts_headline('mycfg', "s"."title"::text, `+tsq+`, 'MaxFragments=1')) AS "ts_headline"`)
+	assert.Contains(t, sql, `ORDER BY ts_rank("ts_vec", `+tsq+`) DESC, id DESC`)
+
+	// 模型没有 TextSearchResult 时只排序
+	tss.SetTsRank(true)
+	q = tss.SiftTS(offlineDB().NewSelect().Model(&Clauses{}), false)
+	sql = q.String()
+	assert.NotContains(t, sql, `AS "ts_rank"`)
+	assert.NotContains(t, sql, "ts_headline")
+	assert.Contains(t, sql, `ORDER BY ts_rank_cd("ts_vec", `+tsq+`) DESC`)
+
+	tss.SearchKeyWord = ""
+	assert.NotContains(t, tss.SiftTS(offlineDB().NewSelect().Model(&data), false).String(), "ts_rank")
+}